@@ -0,0 +1,116 @@
+package audioconv
+
+import (
+    "encoding/binary"
+)
+
+// G.711 audio is 8-bit companded samples at 8kHz
+const G711SampleRate = 8000
+
+// ULawEncode compands little-endian PCM16 samples to G.711 u-law.
+func ULawEncode(pcm []byte) []byte {
+    out := make([]byte, len(pcm)/2)
+    for i := range out {
+        out[i] = linearToULaw(int16(binary.LittleEndian.Uint16(pcm[i*2:])))
+    }
+    return out
+}
+
+// ULawDecode expands G.711 u-law samples to little-endian PCM16.
+func ULawDecode(data []byte) []byte {
+    out := make([]byte, len(data)*2)
+    for i, u := range data {
+        binary.LittleEndian.PutUint16(out[i*2:], uint16(uLawToLinear(u)))
+    }
+    return out
+}
+
+// ALawEncode compands little-endian PCM16 samples to G.711 A-law.
+func ALawEncode(pcm []byte) []byte {
+    out := make([]byte, len(pcm)/2)
+    for i := range out {
+        out[i] = linearToALaw(int16(binary.LittleEndian.Uint16(pcm[i*2:])))
+    }
+    return out
+}
+
+// ALawDecode expands G.711 A-law samples to little-endian PCM16.
+func ALawDecode(data []byte) []byte {
+    out := make([]byte, len(data)*2)
+    for i, a := range data {
+        binary.LittleEndian.PutUint16(out[i*2:], uint16(aLawToLinear(a)))
+    }
+    return out
+}
+
+func linearToULaw(s int16) byte {
+    const bias = 0x84
+    const clip = 32635
+
+    v := int(s)
+    sign := 0
+    if v < 0 {
+        v = -v
+        sign = 0x80
+    }
+    if v > clip {
+        v = clip
+    }
+    v += bias
+
+    exponent := 7
+    for mask := 0x4000; v&mask == 0 && exponent > 0; mask >>= 1 {
+        exponent--
+    }
+    mantissa := (v >> (exponent + 3)) & 0x0F
+    return ^byte(sign | exponent<<4 | mantissa)
+}
+
+func uLawToLinear(u byte) int16 {
+    u = ^u
+    exponent := int(u>>4) & 0x07
+    mantissa := int(u & 0x0F)
+    v := ((mantissa << 3) + 0x84) << exponent
+    v -= 0x84
+    if u&0x80 != 0 {
+        v = -v
+    }
+    return int16(v)
+}
+
+func linearToALaw(s int16) byte {
+    v := int(s)
+    // A-law sets the sign bit for positive samples
+    sign := 0x80
+    if v < 0 {
+        v = -v - 1
+        sign = 0
+    }
+
+    exponent, mantissa := 0, v>>4
+    if v >= 256 {
+        exponent = 7
+        for mask := 0x4000; v&mask == 0 && exponent > 1; mask >>= 1 {
+            exponent--
+        }
+        mantissa = (v >> (exponent + 3)) & 0x0F
+    }
+    return byte(sign|exponent<<4|mantissa) ^ 0x55
+}
+
+func aLawToLinear(a byte) int16 {
+    a ^= 0x55
+    exponent := int(a>>4) & 0x07
+    mantissa := int(a & 0x0F)
+
+    var v int
+    if exponent == 0 {
+        v = mantissa<<4 + 8
+    } else {
+        v = (mantissa<<4 + 0x108) << (exponent - 1)
+    }
+    if a&0x80 == 0 {
+        v = -v
+    }
+    return int16(v)
+}
@@ -0,0 +1,96 @@
+package audioconv
+
+import (
+    "math"
+    "testing"
+)
+
+func TestG711Encode(t *testing.T) {
+    tests := []struct {
+        sample int16
+        ulaw   byte
+        alaw   byte
+    }{
+        {0, 0xff, 0xd5},
+        {-1, 0x7f, 0x55},
+        {1000, 0xce, 0xfa},
+        {-1000, 0x4e, 0x7a},
+        {math.MaxInt16, 0x80, 0xaa},
+        {math.MinInt16, 0x00, 0x2a},
+    }
+    for _, tt := range tests {
+        if got := ULawEncode(pcm16(tt.sample)); len(got) != 1 || got[0] != tt.ulaw {
+            t.Errorf("u-law of %d: got % x, want %02x", tt.sample, got, tt.ulaw)
+        }
+        if got := ALawEncode(pcm16(tt.sample)); len(got) != 1 || got[0] != tt.alaw {
+            t.Errorf("A-law of %d: got % x, want %02x", tt.sample, got, tt.alaw)
+        }
+    }
+}
+
+func TestG711Decode(t *testing.T) {
+    tests := []struct {
+        name   string
+        decode func([]byte) []byte
+        code   byte
+        want   int16
+    }{
+        {"u-law zero", ULawDecode, 0xff, 0},
+        {"u-law negative zero", ULawDecode, 0x7f, 0},
+        {"u-law max", ULawDecode, 0x80, 32124},
+        {"u-law min", ULawDecode, 0x00, -32124},
+        {"A-law smallest", ALawDecode, 0xd5, 8},
+        {"A-law smallest negative", ALawDecode, 0x55, -8},
+        {"A-law max", ALawDecode, 0xaa, 32256},
+        {"A-law min", ALawDecode, 0x2a, -32256},
+    }
+    for _, tt := range tests {
+        if got := samples(tt.decode([]byte{tt.code})); len(got) != 1 || got[0] != tt.want {
+            t.Errorf("%s: %02x decoded to %v, want %d", tt.name, tt.code, got, tt.want)
+        }
+    }
+}
+
+// Every code decodes to a level that encodes back to the same code, bar
+// u-law's negative zero, which shares positive zero's level
+func TestG711RoundTrip(t *testing.T) {
+    for code := 0; code < 256; code++ {
+        b := []byte{byte(code)}
+        if got := ALawEncode(ALawDecode(b)); got[0] != b[0] {
+            t.Errorf("A-law %02x came back as %02x", code, got[0])
+        }
+        want := b[0]
+        if want == 0x7f {
+            want = 0xff
+        }
+        if got := ULawEncode(ULawDecode(b)); got[0] != want {
+            t.Errorf("u-law %02x came back as %02x", code, got[0])
+        }
+    }
+}
+
+func TestG711Error(t *testing.T) {
+    // Companding keeps the error within one quantization step, which
+    // grows with the level: under 1/16 of it, plus a floor near zero
+    for s := math.MinInt16 + 1; s <= math.MaxInt16; s += 7 {
+        in := pcm16(int16(s))
+        limit := math.Abs(float64(s))/16 + 16
+        for name, got := range map[string]int16{
+            "u-law": samples(ULawDecode(ULawEncode(in)))[0],
+            "A-law": samples(ALawDecode(ALawEncode(in)))[0],
+        } {
+            if diff := math.Abs(float64(got) - float64(s)); diff > limit {
+                t.Fatalf("%s: %d came back as %d", name, s, got)
+            }
+        }
+    }
+}
+
+func TestG711Lengths(t *testing.T) {
+    if got := ULawEncode(make([]byte, 5)); len(got) != 2 {
+        t.Errorf("u-law of 5 bytes: got %d codes, want 2", len(got))
+    }
+    if got := ALawDecode(make([]byte, 3)); len(got) != 6 {
+        t.Errorf("A-law of 3 codes: got %d bytes, want 6", len(got))
+    }
+}
@@ -0,0 +1,165 @@
+package audioconv
+
+import (
+    "encoding/binary"
+    "math"
+    "testing"
+)
+
+// pcm16 packs samples as little-endian PCM16
+func pcm16(samples ...int16) []byte {
+    out := make([]byte, 2*len(samples))
+    for i, s := range samples {
+        binary.LittleEndian.PutUint16(out[2*i:], uint16(s))
+    }
+    return out
+}
+
+// samples unpacks little-endian PCM16
+func samples(pcm []byte) []int16 {
+    out := make([]int16, len(pcm)/2)
+    for i := range out {
+        out[i] = int16(binary.LittleEndian.Uint16(pcm[2*i:]))
+    }
+    return out
+}
+
+func TestApplyGain(t *testing.T) {
+    tests := []struct {
+        name string
+        gain float64
+        in   []int16
+        want []int16
+    }{
+        {"unity", 1, []int16{0, 1000, -1000, math.MaxInt16, math.MinInt16}, []int16{0, 1000, -1000, math.MaxInt16, math.MinInt16}},
+        {"half", 0.5, []int16{0, 1000, -1000, 3, -3}, []int16{0, 500, -500, 2, -2}},
+        {"clamps high", 2, []int16{20000, 16383}, []int16{math.MaxInt16, 32766}},
+        {"clamps low", 2, []int16{-20000, -16384}, []int16{math.MinInt16, math.MinInt16}},
+        {"inverts", -1, []int16{1000, math.MinInt16}, []int16{-1000, math.MaxInt16}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := samples(ApplyGain(pcm16(tt.in...), tt.gain))
+            if len(got) != len(tt.want) {
+                t.Fatalf("got %d samples, want %d", len(got), len(tt.want))
+            }
+            for i := range got {
+                if got[i] != tt.want[i] {
+                    t.Errorf("sample %d: got %d, want %d", i, got[i], tt.want[i])
+                }
+            }
+        })
+    }
+}
+
+func TestApplyGainDropsOddByte(t *testing.T) {
+    in := append(pcm16(100), 0x7f)
+    if got := ApplyGain(in, 2); len(got) != 2 || samples(got)[0] != 200 {
+        t.Errorf("got % x, want one sample of 200", got)
+    }
+}
+
+func TestApplyGainLeavesInputAlone(t *testing.T) {
+    in := pcm16(1000)
+    ApplyGain(in, 0.5)
+    if samples(in)[0] != 1000 {
+        t.Errorf("input changed to %d", samples(in)[0])
+    }
+}
+
+func TestToPCM16(t *testing.T) {
+    f32 := make([]byte, 4)
+    binary.LittleEndian.PutUint32(f32, math.Float32bits(-0.5))
+    f64 := make([]byte, 8)
+    binary.LittleEndian.PutUint64(f64, math.Float64bits(0.25))
+
+    tests := []struct {
+        name   string
+        format uint16
+        bits   uint16
+        in     []byte
+        want   []int16
+    }{
+        {"16-bit", FormatPCM, 16, pcm16(-5, 7), []int16{-5, 7}},
+        {"8-bit unsigned", FormatPCM, 8, []byte{0, 128, 255}, []int16{-32768, 0, 32512}},
+        {"24-bit", FormatPCM, 24, []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x80}, []int16{1, -32768}},
+        {"32-bit", FormatPCM, 32, []byte{0x00, 0x00, 0x10, 0x00}, []int16{16}},
+        {"float32", FormatIEEEFloat, 32, f32, []int16{-16384}},
+        {"float64", FormatIEEEFloat, 64, f64, []int16{8192}},
+        {"u-law", FormatMuLaw, 8, []byte{0xff}, []int16{0}},
+        {"A-law", FormatALaw, 8, []byte{0xd5}, []int16{8}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            out, err := ToPCM16(tt.in, tt.format, tt.bits, false)
+            if err != nil {
+                t.Fatal(err)
+            }
+            got := samples(out)
+            if len(got) != len(tt.want) {
+                t.Fatalf("got %v, want %v", got, tt.want)
+            }
+            for i := range got {
+                if got[i] != tt.want[i] {
+                    t.Errorf("got %v, want %v", got, tt.want)
+                    break
+                }
+            }
+        })
+    }
+}
+
+func TestToPCM16Unsupported(t *testing.T) {
+    if _, err := ToPCM16([]byte{0, 0, 0}, FormatIEEEFloat, 24, false); err == nil {
+        t.Error("24-bit float converted, want an error")
+    }
+}
+
+func TestToPCM16Dither(t *testing.T) {
+    // Every 24-bit sample lies halfway between two 16-bit values: 1.5 LSB
+    const n = 4096
+    in := make([]byte, 3*n)
+    for i := 0; i < n; i++ {
+        in[3*i], in[3*i+1] = 0x80, 0x01
+    }
+
+    plain, err := ToPCM16(in, FormatPCM, 24, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, s := range samples(plain) {
+        if s != 2 {
+            t.Fatalf("rounded sample %d, want 2", s)
+        }
+    }
+
+    dithered, err := ToPCM16(in, FormatPCM, 24, true)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var sum float64
+    distinct := map[int16]bool{}
+    for _, s := range samples(dithered) {
+        if s < 0 || s > 3 {
+            t.Fatalf("dithered sample %d, want within 1.5 LSB of 1.5", s)
+        }
+        distinct[s] = true
+        sum += float64(s)
+    }
+    if len(distinct) < 2 {
+        t.Errorf("dithered output is constant, same as plain rounding")
+    }
+    // TPDF dither keeps the average at the true level, which rounding loses
+    if mean := sum / n; math.Abs(mean-1.5) > 0.1 {
+        t.Errorf("dithered mean %.3f, want about 1.5", mean)
+    }
+}
+
+func TestToPCM16DitherSkips8Bit(t *testing.T) {
+    in := []byte{100, 200}
+    plain, _ := ToPCM16(in, FormatPCM, 8, false)
+    dithered, _ := ToPCM16(in, FormatPCM, 8, true)
+    if string(plain) != string(dithered) {
+        t.Errorf("8-bit input dithered: %v, want %v", samples(dithered), samples(plain))
+    }
+}
@@ -0,0 +1,56 @@
+package audioconv
+
+import (
+    "encoding/binary"
+)
+
+// Resample converts little-endian mono PCM16 from one sample rate to
+// another using linear interpolation.
+func Resample(pcm []byte, fromRate, toRate int) []byte {
+    if fromRate == toRate || fromRate <= 0 || toRate <= 0 {
+        return pcm
+    }
+
+    inSamples := len(pcm) / 2
+    if inSamples == 0 {
+        return nil
+    }
+    outSamples := int(int64(inSamples) * int64(toRate) / int64(fromRate))
+    out := make([]byte, outSamples*2)
+
+    step := float64(fromRate) / float64(toRate)
+    sample := func(i int) float64 {
+        if i >= inSamples {
+            i = inSamples - 1
+        }
+        return float64(int16(binary.LittleEndian.Uint16(pcm[i*2:])))
+    }
+
+    for i := 0; i < outSamples; i++ {
+        pos := float64(i) * step
+        idx := int(pos)
+        frac := pos - float64(idx)
+        v := sample(idx)*(1-frac) + sample(idx+1)*frac
+        binary.LittleEndian.PutUint16(out[i*2:], uint16(ClampInt16(v)))
+    }
+    return out
+}
+
+// DownmixMono averages interleaved PCM16 channels into a single channel.
+func DownmixMono(pcm []byte, channels int) []byte {
+    if channels <= 1 {
+        return pcm
+    }
+
+    frameBytes := channels * 2
+    frames := len(pcm) / frameBytes
+    out := make([]byte, frames*2)
+    for f := 0; f < frames; f++ {
+        var sum float64
+        for ch := 0; ch < channels; ch++ {
+            sum += float64(int16(binary.LittleEndian.Uint16(pcm[f*frameBytes+ch*2:])))
+        }
+        binary.LittleEndian.PutUint16(out[f*2:], uint16(ClampInt16(sum/float64(channels))))
+    }
+    return out
+}
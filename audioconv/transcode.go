@@ -0,0 +1,54 @@
+package audioconv
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "os/exec"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// compressedExtensions lists the file types handed to an external decoder
+var compressedExtensions = map[string]bool{
+    ".mp3":  true,
+    ".flac": true,
+    ".ogg":  true,
+    ".oga":  true,
+    ".opus": true,
+    ".m4a":  true,
+}
+
+// IsCompressed reports whether path names a compressed audio format that
+// must be transcoded before upload.
+func IsCompressed(path string) bool {
+    return compressedExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// Transcode decodes any audio ffmpeg understands into mono PCM16 at
+// sampleRate. ffmpeg is the path of the ffmpeg binary.
+func Transcode(ffmpeg string, r io.Reader, sampleRate int) ([]byte, error) {
+    cmd := exec.Command(ffmpeg,
+        "-hide_banner", "-loglevel", "error",
+        "-i", "pipe:0",
+        "-f", "s16le", "-acodec", "pcm_s16le",
+        "-ac", "1", "-ar", strconv.Itoa(sampleRate),
+        "pipe:1",
+    )
+    var stdout, stderr bytes.Buffer
+    cmd.Stdin = r
+    cmd.Stdout = &stdout
+    cmd.Stderr = &stderr
+
+    if err := cmd.Run(); err != nil {
+        if msg := strings.TrimSpace(stderr.String()); msg != "" {
+            return nil, fmt.Errorf("%s: %w: %s", ffmpeg, err, msg)
+        }
+        return nil, fmt.Errorf("%s: %w", ffmpeg, err)
+    }
+    if stdout.Len() == 0 {
+        return nil, fmt.Errorf("%s produced no audio", ffmpeg)
+    }
+    return stdout.Bytes(), nil
+}
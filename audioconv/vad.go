@@ -0,0 +1,97 @@
+package audioconv
+
+import (
+    "encoding/binary"
+    "math"
+    "time"
+)
+
+// VAD is an energy-based voice activity detector for PCM16 audio. Frames
+// whose RMS level exceeds Threshold count as speech; an utterance ends once
+// at least MinSpeech of speech has been followed by SilenceDuration of quiet.
+type VAD struct {
+    Threshold       float64 // RMS level as a fraction of full scale
+    SilenceDuration time.Duration
+    MinSpeech       time.Duration
+    SampleRate      int
+
+    speaking bool
+    speech   time.Duration
+    silence  time.Duration
+}
+
+// NewVAD returns a detector for mono PCM16 at sampleRate.
+func NewVAD(sampleRate int, threshold float64, silence time.Duration) *VAD {
+    return &VAD{
+        Threshold:       threshold,
+        SilenceDuration: silence,
+        MinSpeech:       200 * time.Millisecond,
+        SampleRate:      sampleRate,
+    }
+}
+
+// Process feeds a chunk of PCM16 audio through the detector and reports
+// whether it completed an utterance. The detector resets itself after
+// reporting an end of speech.
+func (v *VAD) Process(pcm []byte) bool {
+    // Analyze in 20ms frames so short bursts inside a chunk are not averaged away
+    frameBytes := v.SampleRate / 50 * 2
+    if frameBytes <= 0 {
+        return false
+    }
+
+    for offset := 0; offset < len(pcm); offset += frameBytes {
+        end := offset + frameBytes
+        if end > len(pcm) {
+            end = len(pcm)
+        }
+        frame := pcm[offset:end]
+        duration := time.Duration(len(frame)/2) * time.Second / time.Duration(v.SampleRate)
+
+        if RMS(frame) >= v.Threshold {
+            v.speaking = true
+            v.speech += duration
+            v.silence = 0
+            continue
+        }
+
+        if v.speaking {
+            v.silence += duration
+            if v.silence >= v.SilenceDuration {
+                ended := v.speech >= v.MinSpeech
+                v.Reset()
+                if ended {
+                    return true
+                }
+            }
+        }
+    }
+    return false
+}
+
+// Speaking reports whether the detector is inside an utterance.
+func (v *VAD) Speaking() bool {
+    return v.speaking
+}
+
+// Reset returns the detector to its idle state.
+func (v *VAD) Reset() {
+    v.speaking = false
+    v.speech = 0
+    v.silence = 0
+}
+
+// RMS returns the root-mean-square level of PCM16 samples as a fraction
+// of full scale.
+func RMS(pcm []byte) float64 {
+    count := len(pcm) / 2
+    if count == 0 {
+        return 0
+    }
+    var sum float64
+    for i := 0; i < count; i++ {
+        sample := float64(int16(binary.LittleEndian.Uint16(pcm[i*2:]))) / 32768
+        sum += sample * sample
+    }
+    return math.Sqrt(sum / float64(count))
+}
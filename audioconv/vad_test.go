@@ -0,0 +1,106 @@
+package audioconv
+
+import (
+    "math"
+    "testing"
+    "time"
+)
+
+const vadRate = 16000
+
+// tone returns d of a 440Hz sine peaking at level of full scale, or of
+// silence for level 0
+func tone(level float64, d time.Duration) []byte {
+    n := int(d * vadRate / time.Second)
+    out := make([]int16, n)
+    for i := range out {
+        out[i] = int16(level * 32767 * math.Sin(2*math.Pi*440*float64(i)/vadRate))
+    }
+    return pcm16(out...)
+}
+
+func TestRMS(t *testing.T) {
+    tests := []struct {
+        name string
+        pcm  []byte
+        want float64
+    }{
+        {"empty", nil, 0},
+        {"silence", pcm16(0, 0, 0), 0},
+        {"square", pcm16(16384, -16384), 0.5},
+        {"sine", tone(0.5, 100*time.Millisecond), 0.5 / math.Sqrt2},
+    }
+    for _, tt := range tests {
+        if got := RMS(tt.pcm); math.Abs(got-tt.want) > 0.001 {
+            t.Errorf("%s: got %.4f, want %.4f", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestVAD(t *testing.T) {
+    speech := func(d time.Duration) []byte { return tone(0.3, d) }
+    quiet := func(d time.Duration) []byte { return tone(0, d) }
+
+    tests := []struct {
+        name   string
+        chunks [][]byte
+        ended  []bool // Process's result per chunk
+    }{
+        {
+            name:   "silence only",
+            chunks: [][]byte{quiet(time.Second)},
+            ended:  []bool{false},
+        },
+        {
+            name:   "speech then enough silence",
+            chunks: [][]byte{speech(400 * time.Millisecond), quiet(300 * time.Millisecond), quiet(300 * time.Millisecond)},
+            ended:  []bool{false, false, true},
+        },
+        {
+            name:   "one chunk holding a whole utterance",
+            chunks: [][]byte{append(speech(400*time.Millisecond), quiet(600*time.Millisecond)...)},
+            ended:  []bool{true},
+        },
+        {
+            name:   "pause shorter than the silence",
+            chunks: [][]byte{speech(400 * time.Millisecond), quiet(200 * time.Millisecond), speech(100 * time.Millisecond), quiet(400 * time.Millisecond)},
+            ended:  []bool{false, false, false, false},
+        },
+        {
+            name:   "click shorter than MinSpeech",
+            chunks: [][]byte{speech(100 * time.Millisecond), quiet(time.Second)},
+            ended:  []bool{false, false},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            vad := NewVAD(vadRate, 0.05, 500*time.Millisecond)
+            for i, chunk := range tt.chunks {
+                if got := vad.Process(chunk); got != tt.ended[i] {
+                    t.Errorf("chunk %d: ended %v, want %v", i, got, tt.ended[i])
+                }
+            }
+        })
+    }
+}
+
+func TestVADSpeakingAndReset(t *testing.T) {
+    vad := NewVAD(vadRate, 0.05, 500*time.Millisecond)
+    vad.Process(tone(0.3, 100*time.Millisecond))
+    if !vad.Speaking() {
+        t.Fatal("not speaking after a tone")
+    }
+    vad.Reset()
+    if vad.Speaking() {
+        t.Error("still speaking after Reset")
+    }
+
+    // A detector that reported an end starts over
+    vad.Process(tone(0.3, 400*time.Millisecond))
+    if !vad.Process(tone(0, 600*time.Millisecond)) {
+        t.Fatal("utterance not ended")
+    }
+    if vad.Speaking() {
+        t.Error("still speaking after the utterance ended")
+    }
+}
@@ -1,27 +1,179 @@
 package audiotypes
 
 import (
+    "compress/gzip"
+    "container/ring"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/csv"
+    "encoding/hex"
     "encoding/json"
+    "fmt"
+    "hash"
+    "io"
+    "log/slog"
+    "math"
+    "net/url"
     "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
     "sync"
     "sync/atomic"
     "time"
-    "log"
+
+    "geppetoaudio/console"
+    "geppetoaudio/subtitles"
+    "geppetoaudio/tracing"
     "github.com/gorilla/websocket"
 )
 
-
 // Configuration types
 type ClientConfig struct {
-    ReadTimeout     time.Duration
-    WriteTimeout    time.Duration
-    PingInterval    time.Duration
-    MaxRetries      int
-    BufferSize      int
-    ShutdownTimeout time.Duration
-    AudioOutputDir  string
+    ReadTimeout           time.Duration `json:"read_timeout"`
+    WriteTimeout          time.Duration `json:"write_timeout"`
+    PingInterval          time.Duration `json:"ping_interval"`
+    MaxRetries            int           `json:"max_retries"`
+    ReconnectBackoff      time.Duration `json:"reconnect_backoff"` // Delay before the first reconnect attempt, doubled after each failure
+    BufferSize            int           `json:"buffer_size"`       // Text turns kept for /history
+    ShutdownTimeout       time.Duration `json:"shutdown_timeout"`
+    AudioOutputDir        string        `json:"audio_output_dir"`
+    OutputGain            float64       `json:"output_gain"`             // Multiplier applied to assistant PCM before saving; 1.0 is identity
+    AutoSave              bool          `json:"auto_save"`               // Write each assistant response to AudioOutputDir as it completes
+    MaxBufferSeconds      float64       `json:"max_buffer_seconds"`      // Commit the input audio buffer at least this often while uploading
+    StuckResponseTimeout  time.Duration `json:"stuck_response_timeout"`  // Finalize buffered audio if no delta or done arrives for this long
+    AckTimeout            time.Duration `json:"ack_timeout"`             // Warn when a sent event is not acknowledged within this long
+    BaseURL               string        `json:"base_url"`                // Realtime WebSocket endpoint, without the model query
+    TurnTimeout           time.Duration `json:"turn_timeout"`            // Cancel a turn that has not completed within this time
+    Dither                bool          `json:"dither"`                  // Add TPDF dither when converting input to 16-bit
+    AudioURLTimeout       time.Duration `json:"audio_url_timeout"`       // Overall timeout for fetching /audiourl input
+    MaxAudioURLBytes      int64         `json:"max_audio_url_bytes"`     // Largest remote audio file accepted by /audiourl
+    APIKey                string        `json:"api_key"`                 // OpenAI API key; redacted by DumpConfig
+    ClientSecret          string        `json:"client_secret"`           // Ephemeral key used instead of APIKey; redacted by DumpConfig
+    SecretURL             string        `json:"secret_url"`              // Endpoint minting a fresh ephemeral key for each connection
+    HashAudio             bool          `json:"hash_audio"`              // Log rolling SHA-256 digests of sent and received audio
+    EmptyTranscriptPolicy string        `json:"empty_transcript_policy"` // One of the EmptyTranscript* policies
+    ReplayHistory         bool          `json:"replay_history"`          // Resend tracked conversation items after a reconnect
+    ClientVAD             bool          `json:"client_vad"`              // Auto-commit streamed audio when the speaker pauses
+    VADThreshold          float64       `json:"vad_threshold"`           // RMS level (fraction of full scale) treated as speech
+    VADSilence            time.Duration `json:"vad_silence"`             // Silence that ends an utterance
+    BargeIn               bool          `json:"barge_in"`                // Cancel and truncate a streaming response when the user interrupts
+    FFmpegPath            string        `json:"ffmpeg_path"`             // ffmpeg binary used to transcode compressed input
+    ContinueTurns         bool          `json:"continue_turns"`          // Split audio over the buffer limit into consecutive turns
+    SplitOverlapSeconds   float64       `json:"split_overlap_seconds"`   // Audio repeated at the start of each split turn
+    ConversationLog       bool          `json:"conversation_log"`        // Write a combined transcript of the whole session
+    ConversationLogPath   string        `json:"conversation_log_path"`   // Where the combined transcript goes; empty uses AudioOutputDir
+    ProtocolLog           bool          `json:"protocol_log"`            // Record every sent and received event in the protocol log
+    Subtitles             bool          `json:"subtitles"`               // Write .srt and .vtt files next to saved responses
+    TurnLog               bool          `json:"turn_log"`                // Append each turn to a session JSONL file
+    LiveCaptions          bool          `json:"live_captions"`           // Print assistant transcript deltas as they arrive
+    OOBInstructions       string        `json:"oob_instructions"`        // Instructions for /oob responses, which see only the session transcript
+    InstructionsFile      string        `json:"instructions_file"`       // File holding the session instructions, reread by /reload
+    LogLevel              string        `json:"log_level"`               // Least severe diagnostics shown on the console: debug, info, warn or error
+    LogFormat             string        `json:"log_format"`              // Console diagnostics as "text" or "json"
+    TraceEndpoint         string        `json:"trace_endpoint"`          // OTLP/HTTP URL for per-turn spans; empty uses OTEL_EXPORTER_OTLP_ENDPOINT
+    LogMaxMB              int           `json:"log_max_mb"`              // Start a new protocol log file after this many megabytes (0 = no limit)
+    LogMaxAge             time.Duration `json:"log_max_age"`             // Start a new protocol log file after this long (0 = no limit)
+    LogKeep               int           `json:"log_keep"`                // Protocol log files to retain (0 = all)
+    LogAudioSent          string        `json:"log_audio_sent"`          // How sent audio payloads are logged: full, trim or hash
+    LogAudioReceived      string        `json:"log_audio_received"`      // How received audio payloads are logged: full, trim or hash
+    LogCompress           bool          `json:"log_compress"`            // Write the protocol log gzip-compressed
+    LogDir                string        `json:"log_dir"`                 // Directory for protocol logs; empty picks the user state directory
+    LogNamePattern        string        `json:"log_name_pattern"`        // Protocol log file name; {time} becomes the start time
+    AudioNamePattern      string        `json:"audio_name_pattern"`      // Saved response file name; {time} becomes the save time
+    RenewSession          bool          `json:"renew_session"`           // Open a new session, replaying the conversation, before the current one expires
+    ExpiryWarning         time.Duration `json:"expiry_warning"`          // Warn this long before the session expires
+    SummaryInterval       time.Duration `json:"summary_interval"`        // Log a one-line metrics summary this often (0 = never)
+    Progress              bool          `json:"progress"`                // Show a spinner and audio counter while a response is pending
+    MetricsOut            string        `json:"metrics_out"`             // File receiving per-turn metrics at shutdown, CSV or JSON by extension
+    SessionSummary        bool          `json:"session_summary"`         // Write session_summary.json to AudioOutputDir at shutdown
+    ContextTokens         int           `json:"context_tokens"`          // Model context size used for automatic pruning (0 = never prune)
+    PruneThreshold        float64       `json:"prune_threshold"`         // Prune the oldest items once input tokens exceed this fraction of ContextTokens
+    Prices                PriceTable    `json:"prices"`                  // Token prices for the session cost estimate
+    RateLimitReserve      float64       `json:"rate_limit_reserve"`      // Pause sending when less than this fraction of a rate limit remains
+    TUI                   bool          `json:"tui"`                     // Run interactive sessions in the full-screen interface
+    NoColor               bool          `json:"no_color"`                // Never style console output, even on a terminal
+}
+
+// Duration is a time.Duration written to JSON as a string such as "1m30s"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+    return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+    var s string
+    if err := json.Unmarshal(data, &s); err != nil {
+        return fmt.Errorf("duration must be a string such as \"30s\": %w", err)
+    }
+    parsed, err := time.ParseDuration(s)
+    if err != nil {
+        return err
+    }
+    *d = Duration(parsed)
+    return nil
+}
+
+// plainClientConfig is ClientConfig without its JSON methods
+type plainClientConfig ClientConfig
+
+// clientConfigJSON is how ClientConfig looks in JSON: its durations are
+// overlaid with Duration so they read as strings rather than nanoseconds
+type clientConfigJSON struct {
+    *plainClientConfig
+    ReadTimeout          *Duration `json:"read_timeout"`
+    WriteTimeout         *Duration `json:"write_timeout"`
+    PingInterval         *Duration `json:"ping_interval"`
+    ReconnectBackoff     *Duration `json:"reconnect_backoff"`
+    ShutdownTimeout      *Duration `json:"shutdown_timeout"`
+    StuckResponseTimeout *Duration `json:"stuck_response_timeout"`
+    AckTimeout           *Duration `json:"ack_timeout"`
+    TurnTimeout          *Duration `json:"turn_timeout"`
+    AudioURLTimeout      *Duration `json:"audio_url_timeout"`
+    VADSilence           *Duration `json:"vad_silence"`
+    LogMaxAge            *Duration `json:"log_max_age"`
+    ExpiryWarning        *Duration `json:"expiry_warning"`
+    SummaryInterval      *Duration `json:"summary_interval"`
+}
+
+// jsonView returns c as JSON sees it, sharing c's fields
+func (c *ClientConfig) jsonView() clientConfigJSON {
+    return clientConfigJSON{
+        plainClientConfig:    (*plainClientConfig)(c),
+        ReadTimeout:          (*Duration)(&c.ReadTimeout),
+        WriteTimeout:         (*Duration)(&c.WriteTimeout),
+        PingInterval:         (*Duration)(&c.PingInterval),
+        ReconnectBackoff:     (*Duration)(&c.ReconnectBackoff),
+        ShutdownTimeout:      (*Duration)(&c.ShutdownTimeout),
+        StuckResponseTimeout: (*Duration)(&c.StuckResponseTimeout),
+        AckTimeout:           (*Duration)(&c.AckTimeout),
+        TurnTimeout:          (*Duration)(&c.TurnTimeout),
+        AudioURLTimeout:      (*Duration)(&c.AudioURLTimeout),
+        VADSilence:           (*Duration)(&c.VADSilence),
+        LogMaxAge:            (*Duration)(&c.LogMaxAge),
+        ExpiryWarning:        (*Duration)(&c.ExpiryWarning),
+        SummaryInterval:      (*Duration)(&c.SummaryInterval),
+    }
 }
 
+func (c ClientConfig) MarshalJSON() ([]byte, error) {
+    return json.Marshal(c.jsonView())
+}
+
+func (c *ClientConfig) UnmarshalJSON(data []byte) error {
+    view := c.jsonView()
+    return json.Unmarshal(data, &view)
+}
+
+// Policies for responses whose audio arrives without a transcript
+const (
+    EmptyTranscriptPlaceholder = "placeholder" // Write "No transcript available"
+    EmptyTranscriptSkip        = "skip"        // Don't write a transcript file
+    EmptyTranscriptRetry       = "retry"       // Request the response once more
+)
+
 // Audio handling types
 type AudioChunk struct {
     ResponseID   string
@@ -29,12 +181,78 @@ type AudioChunk struct {
     OutputIndex  int
     ContentIndex int
     Data         []byte
+    Flushed      chan struct{} // When set, closed once earlier chunks are processed
+}
+
+// Kinds of Output
+const (
+    OutputTranscript     = "transcript"      // A delta of the assistant's transcript
+    OutputUserTranscript = "user_transcript" // The transcription of the user's speech
+    OutputAudio          = "audio"           // A chunk of assistant audio in the session's output format
+    OutputDone           = "done"            // A response ended; Status says how and Text is its transcript
+    OutputError          = "error"           // A server error
+)
+
+// Output is one piece of what a session produced, handed to OnOutput for
+// code that embeds the client instead of reading the console
+type Output struct {
+    Kind       string
+    ResponseID string
+    Text       string
+    Audio      []byte
+    Status     string
+    AudioPath  string            // Set on OutputDone when the response's audio was saved
+    Metadata   map[string]string // The response's metadata, on OutputDone
 }
 
+// ActiveResponse tracks the assistant response currently streaming audio
+type ActiveResponse struct {
+    ResponseID    string
+    ItemID        string
+    ContentIndex  int
+    ReceivedBytes int64
+}
+
+// AudioMessage is the record of one assistant response item: its audio,
+// transcript and subtitle deltas are collected here until the response is
+// done and the files can be written together
 type AudioMessage struct {
+    ResponseID string
+    ItemID     string
+    AudioDone  bool // response.audio.done has been received
     Transcript string
     AudioData  []byte
     Complete   bool
+    Updated    time.Time    // Arrival time of the most recent chunk
+    Hash       *RollingHash // Digest of received chunks when HashAudio is set
+    Captions   []subtitles.Delta
+}
+
+// RollingHash accumulates a SHA-256 digest over a stream of audio chunks so
+// sent and received audio can be compared chunk by chunk
+type RollingHash struct {
+    hash   hash.Hash
+    Chunks int
+    Bytes  int64
+}
+
+func NewRollingHash() *RollingHash {
+    return &RollingHash{hash: sha256.New()}
+}
+
+// Add folds a chunk into the digest and returns the chunk's own digest and
+// the rolling digest of everything added so far
+func (r *RollingHash) Add(chunk []byte) (chunkSum, rollingSum string) {
+    r.Chunks++
+    r.Bytes += int64(len(chunk))
+    r.hash.Write(chunk)
+    sum := sha256.Sum256(chunk)
+    return hex.EncodeToString(sum[:]), r.Sum()
+}
+
+// Sum returns the hex digest of all chunks added so far
+func (r *RollingHash) Sum() string {
+    return hex.EncodeToString(r.hash.Sum(nil))
 }
 
 type CompleteResponse struct {
@@ -45,6 +263,7 @@ type CompleteResponse struct {
         Output []struct {
             Content []struct {
                 Type       string `json:"type"`
+                Text       string `json:"text"`
                 Transcript string `json:"transcript"`
             } `json:"content"`
             ID     string `json:"id"`
@@ -53,16 +272,174 @@ type CompleteResponse struct {
             Status string `json:"status"`
             Type   string `json:"type"`
         } `json:"output"`
-        Status        string      `json:"status"`
-        StatusDetails interface{} `json:"status_details"`
-        Usage         struct {
-            InputTokens  int `json:"input_tokens"`
-            OutputTokens int `json:"output_tokens"`
-            TotalTokens  int `json:"total_tokens"`
-        } `json:"usage"`
+        Status        string            `json:"status"`
+        StatusDetails *StatusDetails    `json:"status_details"`
+        Usage         Usage             `json:"usage"`
+        Metadata      map[string]string `json:"metadata"`
     } `json:"response"`
 }
 
+// StatusDetails explains why a response was cancelled, incomplete or failed
+type StatusDetails struct {
+    Type   string       `json:"type"`
+    Reason string       `json:"reason"`
+    Error  *ServerError `json:"error"`
+}
+
+// ClientSecret is a short-lived key minted by the REST sessions endpoint
+type ClientSecret struct {
+    Value     string `json:"value"`
+    ExpiresAt int64  `json:"expires_at"`
+}
+
+// Usage reports the tokens consumed by a response
+type Usage struct {
+    InputTokens        int                `json:"input_tokens"`
+    OutputTokens       int                `json:"output_tokens"`
+    TotalTokens        int                `json:"total_tokens"`
+    InputTokenDetails  InputTokenDetails  `json:"input_token_details"`
+    OutputTokenDetails OutputTokenDetails `json:"output_token_details"`
+}
+
+// InputTokenDetails splits input tokens by modality; cached tokens are
+// included in the text and audio counts
+type InputTokenDetails struct {
+    TextTokens          int `json:"text_tokens"`
+    AudioTokens         int `json:"audio_tokens"`
+    CachedTokens        int `json:"cached_tokens"`
+    CachedTokensDetails struct {
+        TextTokens  int `json:"text_tokens"`
+        AudioTokens int `json:"audio_tokens"`
+    } `json:"cached_tokens_details"`
+}
+
+// OutputTokenDetails splits output tokens by modality
+type OutputTokenDetails struct {
+    TextTokens  int `json:"text_tokens"`
+    AudioTokens int `json:"audio_tokens"`
+}
+
+// Add accumulates the counts of another response into u
+func (u *Usage) Add(o Usage) {
+    u.InputTokens += o.InputTokens
+    u.OutputTokens += o.OutputTokens
+    u.TotalTokens += o.TotalTokens
+    u.InputTokenDetails.TextTokens += o.InputTokenDetails.TextTokens
+    u.InputTokenDetails.AudioTokens += o.InputTokenDetails.AudioTokens
+    u.InputTokenDetails.CachedTokens += o.InputTokenDetails.CachedTokens
+    u.InputTokenDetails.CachedTokensDetails.TextTokens += o.InputTokenDetails.CachedTokensDetails.TextTokens
+    u.InputTokenDetails.CachedTokensDetails.AudioTokens += o.InputTokenDetails.CachedTokensDetails.AudioTokens
+    u.OutputTokenDetails.TextTokens += o.OutputTokenDetails.TextTokens
+    u.OutputTokenDetails.AudioTokens += o.OutputTokenDetails.AudioTokens
+}
+
+// PriceTable holds token prices in US dollars per million tokens
+type PriceTable struct {
+    TextInput        float64 `json:"text_input"`
+    TextCachedInput  float64 `json:"text_cached_input"`
+    TextOutput       float64 `json:"text_output"`
+    AudioInput       float64 `json:"audio_input"`
+    AudioCachedInput float64 `json:"audio_cached_input"`
+    AudioOutput      float64 `json:"audio_output"`
+}
+
+// DefaultPrices are the published gpt-4o-realtime-preview prices
+var DefaultPrices = PriceTable{
+    TextInput:        5,
+    TextCachedInput:  2.5,
+    TextOutput:       20,
+    AudioInput:       40,
+    AudioCachedInput: 2.5,
+    AudioOutput:      80,
+}
+
+// Cost estimates what usage costs in US dollars
+func (p PriceTable) Cost(u Usage) float64 {
+    in := u.InputTokenDetails
+    cached := in.CachedTokensDetails
+    total := float64(in.TextTokens-cached.TextTokens)*p.TextInput +
+        float64(cached.TextTokens)*p.TextCachedInput +
+        float64(in.AudioTokens-cached.AudioTokens)*p.AudioInput +
+        float64(cached.AudioTokens)*p.AudioCachedInput +
+        float64(u.OutputTokenDetails.TextTokens)*p.TextOutput +
+        float64(u.OutputTokenDetails.AudioTokens)*p.AudioOutput
+    return total / 1e6
+}
+
+// TurnRecord is one turn of the conversation as written to the session
+// JSONL transcript
+type TurnRecord struct {
+    Timestamp  time.Time `json:"timestamp"`
+    Role       string    `json:"role"`
+    Kind       string    `json:"kind"` // "text", "audio" or "transcription"
+    Text       string    `json:"text,omitempty"`
+    AudioPath  string    `json:"audio_path,omitempty"`
+    DurationMs int64     `json:"duration_ms,omitempty"`
+    ResponseID string    `json:"response_id,omitempty"`
+    ItemID     string    `json:"item_id,omitempty"`
+    Usage      *Usage    `json:"usage,omitempty"`
+}
+
+// SessionSummary is the manifest written to session_summary.json when
+// the client shuts down
+type SessionSummary struct {
+    SessionID       string           `json:"session_id,omitempty"`
+    Started         time.Time        `json:"started"`
+    Ended           time.Time        `json:"ended"`
+    Config          ClientConfig     `json:"config"`
+    Session         Session          `json:"session"`
+    Turns           int              `json:"turns"`
+    AudioFiles      []SummaryAudio   `json:"audio_files"`
+    Usage           Usage            `json:"usage"`
+    EstimatedCost   float64          `json:"estimated_cost_usd"`
+    Errors          int64            `json:"errors"`
+    ServerErrors    map[string]int64 `json:"server_errors,omitempty"`
+    Unfinished      map[string]int64 `json:"unfinished,omitempty"`
+    ProtocolLogs    []string         `json:"protocol_logs,omitempty"`
+    ConversationLog string           `json:"conversation_log,omitempty"`
+    TurnLog         string           `json:"turn_log,omitempty"`
+}
+
+// SummaryAudio is one saved assistant response in a SessionSummary
+type SummaryAudio struct {
+    Path       string `json:"path"`
+    DurationMs int64  `json:"duration_ms"`
+    ResponseID string `json:"response_id,omitempty"`
+}
+
+// ServerErrorEvent is the payload of an "error" event
+type ServerErrorEvent struct {
+    Type  string      `json:"type"`
+    Error ServerError `json:"error"`
+}
+
+// ServerError describes what went wrong; EventID names the client event
+// that caused it, when there was one
+type ServerError struct {
+    Type    string `json:"type"`
+    Code    string `json:"code"`
+    Message string `json:"message"`
+    Param   string `json:"param"`
+    EventID string `json:"event_id"`
+}
+
+// ServerCapabilities lists the options a realtime server accepts
+type ServerCapabilities struct {
+    Modalities   []string `json:"modalities"`
+    Voices       []string `json:"voices"`
+    AudioFormats []string `json:"audio_formats"`
+}
+
+// DefaultCapabilities returns the documented options of the realtime API,
+// used until the server lists its own
+func DefaultCapabilities() ServerCapabilities {
+    return ServerCapabilities{
+        Modalities:   []string{"text", "audio"},
+        Voices:       []string{"alloy", "ash", "ballad", "coral", "echo", "sage", "shimmer", "verse"},
+        AudioFormats: []string{"pcm16", "g711_ulaw", "g711_alaw"},
+    }
+}
+
 // Message types for WebSocket communication
 type SessionUpdate struct {
     Type    string  `json:"type"`
@@ -70,13 +447,96 @@ type SessionUpdate struct {
 }
 
 type Session struct {
-    Modalities              []string `json:"modalities"`
-    Instructions            string   `json:"instructions"`
-    Temperature             float64  `json:"temperature"`
-    MaxResponseOutputTokens int      `json:"max_response_output_tokens"`
-    Voice                   string   `json:"voice"`
-    InputAudioFormat        string   `json:"input_audio_format"`
-    OutputAudioFormat       string   `json:"output_audio_format"`
+    Modalities              []string       `json:"modalities"`
+    Instructions            string         `json:"instructions"`
+    Temperature             float64        `json:"temperature"`
+    MaxResponseOutputTokens int            `json:"max_response_output_tokens"`
+    Voice                   string         `json:"voice"`
+    InputAudioFormat        string         `json:"input_audio_format"`
+    OutputAudioFormat       string         `json:"output_audio_format"`
+    TurnDetection           *TurnDetection `json:"turn_detection,omitempty"`
+    Tools                   []Tool         `json:"tools,omitempty"`
+
+    InputAudioTranscription *InputAudioTranscription `json:"input_audio_transcription,omitempty"`
+}
+
+// InputAudioTranscription asks the server to transcribe the user's audio
+type InputAudioTranscription struct {
+    Model string `json:"model"`
+}
+
+// InputTranscriptionCompleted carries the transcript of a user audio item
+type InputTranscriptionCompleted struct {
+    Type         string `json:"type"`
+    ItemID       string `json:"item_id"`
+    ContentIndex int    `json:"content_index"`
+    Transcript   string `json:"transcript"`
+}
+
+// Audio formats for Session.InputAudioFormat and OutputAudioFormat
+const (
+    AudioFormatPCM16    = "pcm16"     // 24kHz mono little-endian 16-bit
+    AudioFormatG711ULaw = "g711_ulaw" // 8kHz u-law
+    AudioFormatG711ALaw = "g711_alaw" // 8kHz A-law
+)
+
+// Tool describes a function the model may call. Parameters is a JSON
+// schema for the call arguments.
+type Tool struct {
+    Type        string          `json:"type"`
+    Name        string          `json:"name"`
+    Description string          `json:"description,omitempty"`
+    Parameters  json.RawMessage `json:"parameters"`
+}
+
+// ToolFunc handles a function call. It receives the raw JSON arguments
+// from the model and returns the output to send back.
+type ToolFunc func(arguments json.RawMessage) (string, error)
+
+// FunctionCallArgumentsDone is sent when the model has finished streaming
+// the arguments of a function call
+type FunctionCallArgumentsDone struct {
+    Type        string `json:"type"`
+    ResponseID  string `json:"response_id"`
+    ItemID      string `json:"item_id"`
+    OutputIndex int    `json:"output_index"`
+    CallID      string `json:"call_id"`
+    Name        string `json:"name"`
+    Arguments   string `json:"arguments"`
+}
+
+// FunctionCallOutput returns the result of a function call to the conversation
+type FunctionCallOutput struct {
+    Type string `json:"type"`
+    Item struct {
+        Type   string `json:"type"`
+        CallID string `json:"call_id"`
+        Output string `json:"output"`
+    } `json:"item"`
+}
+
+// TurnDetection configures server-side voice activity detection. A nil
+// TurnDetection leaves the server default in place; Type "none" disables
+// detection so turns are only committed by the client.
+type TurnDetection struct {
+    Type              string  `json:"type"`
+    Threshold         float64 `json:"threshold,omitempty"`
+    PrefixPaddingMs   int     `json:"prefix_padding_ms,omitempty"`
+    SilenceDurationMs int     `json:"silence_duration_ms,omitempty"`
+}
+
+// MarshalJSON encodes Type "none" as null, which the API reads as disabled
+func (t TurnDetection) MarshalJSON() ([]byte, error) {
+    if t.Type == "none" {
+        return []byte("null"), nil
+    }
+    type plain TurnDetection
+    return json.Marshal(plain(t))
+}
+
+// ServerVAD reports whether the session has the server detecting turns
+func (s Session) ServerVAD() bool {
+    return s.TurnDetection != nil && s.TurnDetection.Type == "server_vad"
 }
 
 type ConversationItem struct {
@@ -92,9 +552,57 @@ type ConversationItem struct {
 }
 
 type ResponseCreate struct {
+    Type     string           `json:"type"`
+    Response *ResponseOptions `json:"response,omitempty"`
+}
+
+// ResponseOptions overrides the session for a single response. With
+// Conversation "none" the response is out-of-band: it sees only Input and
+// is not added to the conversation.
+type ResponseOptions struct {
+    Conversation string              `json:"conversation,omitempty"`
+    Instructions string              `json:"instructions,omitempty"`
+    Modalities   []string            `json:"modalities,omitempty"`
+    Metadata     map[string]string   `json:"metadata,omitempty"`
+    Input        []ResponseInputItem `json:"input,omitempty"`
+    Temperature  float64             `json:"temperature,omitempty"`
+}
+
+// ResponseInputItem is a message given to an out-of-band response
+type ResponseInputItem struct {
+    Type    string                 `json:"type"`
+    Role    string                 `json:"role"`
+    Content []ResponseInputContent `json:"content"`
+}
+
+type ResponseInputContent struct {
+    Type string `json:"type"`
+    Text string `json:"text"`
+}
+
+// OOBPurpose tags the metadata of out-of-band responses
+const OOBPurpose = "oob"
+
+// TurnKey is the metadata key under which responses carry ChatClient.TurnTag,
+// so whoever started a turn can pick its response.done out from late ones
+const TurnKey = "turn"
+
+type ResponseCancel struct {
     Type string `json:"type"`
 }
 
+type ConversationItemDelete struct {
+    Type   string `json:"type"`
+    ItemID string `json:"item_id"`
+}
+
+type ConversationItemTruncate struct {
+    Type         string `json:"type"`
+    ItemID       string `json:"item_id"`
+    ContentIndex int    `json:"content_index"`
+    AudioEndMs   int    `json:"audio_end_ms"`
+}
+
 type ResponseMessage struct {
     Type     string   `json:"type"`
     Response Response `json:"response"`
@@ -122,21 +630,497 @@ type ChatMessage struct {
     Content string
 }
 
+// MessageRing holds the most recent chat messages, dropping the oldest once
+// it is full. It is safe for concurrent use.
+type MessageRing struct {
+    mu    sync.Mutex
+    next  *ring.Ring // Slot the next message is written to
+    size  int
+    count int
+}
+
+// NewMessageRing returns a ring holding up to size messages
+func NewMessageRing(size int) *MessageRing {
+    if size < 1 {
+        size = 1
+    }
+    return &MessageRing{next: ring.New(size), size: size}
+}
+
+// Add stores msg, replacing the oldest message when the ring is full
+func (m *MessageRing) Add(msg ChatMessage) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.next.Value = msg
+    m.next = m.next.Next()
+    if m.count < m.size {
+        m.count++
+    }
+}
+
+// Clear drops every message
+func (m *MessageRing) Clear() {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.next = ring.New(m.size)
+    m.count = 0
+}
+
+// Last returns up to n of the newest messages, oldest first, or all of them
+// when n <= 0
+func (m *MessageRing) Last(n int) []ChatMessage {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if n <= 0 || n > m.count {
+        n = m.count
+    }
+    msgs := make([]ChatMessage, 0, n)
+    for r := m.next.Move(-n); len(msgs) < n; r = r.Next() {
+        msgs = append(msgs, r.Value.(ChatMessage))
+    }
+    return msgs
+}
+
 // Metrics tracking
 type Metrics struct {
     MessagesSent     int64
     MessagesReceived int64
     Errors           int64
     Latencies        []time.Duration
+    FirstAudio       []time.Duration // Time from response.create to the first audio delta, guarded by Mu
+    Turns            []TurnMetrics   // Every finished response that was requested, guarded by Mu
     AudioChunks      int64
+    LateChunks       int64                // Audio deltas after their item's response.audio.done, accessed atomically
+    OutOfOrder       int64                // Audio deltas whose output or content index went backwards, accessed atomically
+    IndexGaps        int64                // Audio deltas skipping an output or content index, accessed atomically
+    ChannelStalls    int64                // Audio deltas that waited for a full processing channel; none are dropped, accessed atomically
+    AudioInSamples   int64                // Input audio sent, in 24kHz samples, accessed atomically
+    AudioOutSamples  int64                // Response audio received, in 24kHz samples, accessed atomically
+    Reconnects       int64                // Successful reconnects, accessed atomically
+    ServerErrors     map[string]int64     // Server error events by code, guarded by Mu
+    RateLimits       map[string]RateLimit // Latest rate limits by name, guarded by Mu
+    Unfinished       map[string]int64     // Responses ending cancelled, incomplete or failed, by status, guarded by Mu
+    Usage            Usage                // Tokens used by every response this session, guarded by Mu
+    Responses        int64                // Responses whose usage was recorded, guarded by Mu
     Mu               sync.Mutex
 }
 
+// AudioPosition is where an audio delta sits in its response
+type AudioPosition struct {
+    Output  int
+    Content int
+}
+
+// TurnMetrics measures one requested response, from response.create to
+// response.done
+type TurnMetrics struct {
+    ResponseID   string    `json:"response_id"`
+    Requested    time.Time `json:"requested"`
+    Done         time.Time `json:"done"`
+    Status       string    `json:"status"`
+    LatencyMs    int64     `json:"latency_ms"`
+    FirstAudioMs int64     `json:"first_audio_ms"` // 0 when no audio arrived
+    AudioBytes   int64     `json:"audio_bytes"`
+    Usage        Usage     `json:"usage"`
+}
+
+// RateLimit is one entry of a rate_limits.updated event
+type RateLimit struct {
+    Name         string    `json:"name"`
+    Limit        int       `json:"limit"`
+    Remaining    int       `json:"remaining"`
+    ResetSeconds float64   `json:"reset_seconds"`
+    Updated      time.Time `json:"-"`
+}
+
+// ResetAt is when the limit is expected to be replenished
+func (r RateLimit) ResetAt() time.Time {
+    return r.Updated.Add(time.Duration(r.ResetSeconds * float64(time.Second)))
+}
+
+// PacedSend is an event held back until the rate limits it counts against
+// have room again
+type PacedSend struct {
+    Limits []string     // Names of the rate limits the event uses
+    Send   func() error // Writes the event
+    Desc   string       // Names the event when its send fails
+}
+
 // Logger implementation
 type Logger struct {
     File    *os.File
     Mu      sync.Mutex
     Encoder *json.Encoder
+
+    // Rotation: a new file is started once the current one reaches
+    // MaxBytes (uncompressed) or MaxAge, and only the newest Keep files are
+    // retained. Zero values disable each limit.
+    Dir      string
+    Pattern  string // File name without extension; {time} becomes the start time
+    MaxBytes int64
+    MaxAge   time.Duration
+    Keep     int
+    written  int64
+    opened   time.Time
+
+    // Compress writes .log.gz files
+    Compress bool
+    out      io.Writer
+    gz       *gzip.Writer
+
+    // Entries are queued and written by a background goroutine, which
+    // flushes and syncs the file every FlushInterval (default 1s) so a
+    // crash loses little and readers can follow it. Close drains the queue.
+    FlushInterval time.Duration
+    queue         chan []byte
+    done          chan struct{}
+    closed        bool
+
+    // files lists every log file opened, guarded by filesMu since the
+    // writer opens new ones when rotating
+    files   []string
+    filesMu sync.Mutex
+
+    // AudioPolicy says how audio payloads are logged per direction
+    // ("sent" or "received"): one of the LogAudio* policies, full if unset
+    AudioPolicy map[string]string
+}
+
+// Audio payload log policies
+const (
+    LogAudioFull = "full" // Keep the base64 payload
+    LogAudioTrim = "trim" // Replace it with its decoded size
+    LogAudioHash = "hash" // Replace it with the SHA-256 of the decoded audio
+)
+
+// audioFields names the base64 audio field of each event type carrying one
+var audioFields = map[string]string{
+    "response.audio.delta":      "delta",
+    "input_audio_buffer.append": "audio",
+    "audio.data":                "data",
+}
+
+// redactAudio returns content with its audio payload replaced according
+// to policy. Sent events arrive as raw JSON fields and received ones as
+// decoded values; other content is returned unchanged.
+func redactAudio(msgType string, content interface{}, policy string) interface{} {
+    field, ok := audioFields[msgType]
+    if !ok || policy == "" || policy == LogAudioFull {
+        return content
+    }
+
+    summarize := func(encoded string) string {
+        audio, err := base64.StdEncoding.DecodeString(encoded)
+        if err != nil {
+            return fmt.Sprintf("[trimmed: %d base64 chars]", len(encoded))
+        }
+        if policy == LogAudioHash {
+            sum := sha256.Sum256(audio)
+            return fmt.Sprintf("[sha256: %s, %d bytes]", hex.EncodeToString(sum[:]), len(audio))
+        }
+        return fmt.Sprintf("[trimmed: %d bytes]", len(audio))
+    }
+
+    switch event := content.(type) {
+    case map[string]json.RawMessage:
+        var encoded string
+        if raw, ok := event[field]; !ok || json.Unmarshal(raw, &encoded) != nil {
+            return content
+        }
+        redacted := make(map[string]json.RawMessage, len(event))
+        for k, v := range event {
+            redacted[k] = v
+        }
+        redacted[field], _ = json.Marshal(summarize(encoded))
+        return redacted
+    case map[string]interface{}:
+        encoded, ok := event[field].(string)
+        if !ok {
+            return content
+        }
+        redacted := make(map[string]interface{}, len(event))
+        for k, v := range event {
+            redacted[k] = v
+        }
+        redacted[field] = summarize(encoded)
+        return redacted
+    }
+    return content
+}
+
+// windowsReserved are device names Windows refuses as file names, with or
+// without an extension
+var windowsReserved = map[string]bool{
+    "CON": true, "PRN": true, "AUX": true, "NUL": true,
+    "COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+    "LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeFilename makes name usable as a file name on Windows, macOS and
+// Unix: path separators, characters Windows reserves and control
+// characters become '_', trailing dots and spaces are dropped, and
+// reserved device names get a '_' suffix
+func SanitizeFilename(name string) string {
+    clean := strings.TrimRight(replaceUnsafe(name), ". ")
+    if clean == "" {
+        return "_"
+    }
+    stem := clean
+    if i := strings.IndexByte(stem, '.'); i >= 0 {
+        stem = stem[:i]
+    }
+    if windowsReserved[strings.ToUpper(stem)] {
+        clean = stem + "_" + clean[len(stem):]
+    }
+    return clean
+}
+
+// replaceUnsafe replaces characters that are not portable in file names
+func replaceUnsafe(name string) string {
+    return strings.Map(func(r rune) rune {
+        if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"/\|?*`, r) {
+            return '_'
+        }
+        return r
+    }, name)
+}
+
+// ExpandNamePattern builds a sanitized file name from a naming pattern,
+// replacing {time} with t as 20060102_150405. Patterns without {time} get
+// it appended so successive files do not collide.
+func ExpandNamePattern(pattern string, t time.Time) string {
+    if !strings.Contains(pattern, "{time}") {
+        pattern += "_{time}"
+    }
+    return SanitizeFilename(strings.ReplaceAll(pattern, "{time}", t.Format("20060102_150405")))
+}
+
+// namePatternGlob matches every name ExpandNamePattern produces for pattern
+func namePatternGlob(pattern string) string {
+    if !strings.Contains(pattern, "{time}") {
+        pattern += "_{time}"
+    }
+    parts := strings.Split(pattern, "{time}")
+    for i, part := range parts {
+        parts[i] = strings.ReplaceAll(replaceUnsafe(part), "[", "[[]")
+    }
+    return strings.Join(parts, "*")
+}
+
+// logQueueSize is how many entries may wait for the writer before Entry
+// blocks
+const logQueueSize = 4096
+
+// Open creates the first log file, named from Pattern in Dir,
+// prunes files left by earlier runs beyond Keep and starts the writer
+func (l *Logger) Open() error {
+    l.Mu.Lock()
+    defer l.Mu.Unlock()
+    if err := l.open(); err != nil {
+        return err
+    }
+    if err := l.prune(); err != nil {
+        slog.Warn("pruning old log files", "dir", l.Dir, "err", err)
+    }
+
+    l.queue = make(chan []byte, logQueueSize)
+    l.done = make(chan struct{})
+    go l.run()
+    return nil
+}
+
+// run writes queued entries until the queue is closed, syncing the file
+// whenever entries were written during the last FlushInterval
+func (l *Logger) run() {
+    defer close(l.done)
+
+    interval := l.FlushInterval
+    if interval <= 0 {
+        interval = time.Second
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    var failed error
+    report := func(err error) {
+        // Log asynchronously: slog may write back into this logger
+        if err != nil && failed == nil {
+            go slog.Error("writing protocol log", "err", err)
+        }
+        failed = err
+    }
+
+    dirty := false
+    for {
+        select {
+        case line, ok := <-l.queue:
+            if !ok {
+                if dirty {
+                    report(l.sync())
+                }
+                return
+            }
+            report(l.write(line))
+            dirty = true
+        case <-ticker.C:
+            if dirty {
+                report(l.sync())
+                dirty = false
+            }
+        }
+    }
+}
+
+// write appends one encoded entry, rotating the file when it is full
+func (l *Logger) write(line []byte) error {
+    n, err := l.out.Write(line)
+    l.written += int64(n)
+    if err != nil {
+        return err
+    }
+    if l.MaxBytes > 0 && l.written >= l.MaxBytes || l.MaxAge > 0 && time.Since(l.opened) >= l.MaxAge {
+        return l.rotate()
+    }
+    return nil
+}
+
+// sync pushes buffered compressed data and written entries to disk
+func (l *Logger) sync() error {
+    if l.gz != nil {
+        if err := l.gz.Flush(); err != nil {
+            return err
+        }
+    }
+    return l.File.Sync()
+}
+
+// open starts a new log file, adding a sequence number when one with the
+// same timestamp already exists
+func (l *Logger) open() error {
+    ext := ".log"
+    if l.Compress {
+        ext = ".log.gz"
+    }
+    base := filepath.Join(l.Dir, ExpandNamePattern(l.Pattern, time.Now()))
+    name := base + ext
+    for i := 1; ; i++ {
+        file, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+        if err == nil {
+            l.File = file
+            l.out = file
+            l.gz = nil
+            if l.Compress {
+                l.gz = gzip.NewWriter(file)
+                l.out = l.gz
+            }
+            l.Encoder = json.NewEncoder(l.out)
+            l.written = 0
+            l.opened = time.Now()
+            l.filesMu.Lock()
+            l.files = append(l.files, name)
+            l.filesMu.Unlock()
+            return nil
+        }
+        if !os.IsExist(err) {
+            return fmt.Errorf("create log file: %w", err)
+        }
+        name = fmt.Sprintf("%s_%d%s", base, i, ext)
+    }
+}
+
+// Files returns the paths of the log files written this session, oldest
+// first; earlier ones may since have been pruned
+func (l *Logger) Files() []string {
+    l.filesMu.Lock()
+    defer l.filesMu.Unlock()
+    return append([]string(nil), l.files...)
+}
+
+// closeFile finishes the gzip stream, if any, and closes the current file
+func (l *Logger) closeFile() error {
+    var err error
+    if l.gz != nil {
+        err = l.gz.Close()
+        l.gz = nil
+    }
+    if cerr := l.File.Close(); err == nil {
+        err = cerr
+    }
+    l.File = nil
+    return err
+}
+
+// rotate replaces the current log file with a new one and prunes files
+// beyond Keep
+func (l *Logger) rotate() error {
+    if err := l.closeFile(); err != nil {
+        return err
+    }
+    if err := l.open(); err != nil {
+        return err
+    }
+    return l.prune()
+}
+
+// prune deletes the oldest log files so at most Keep remain
+func (l *Logger) prune() error {
+    if l.Keep <= 0 {
+        return nil
+    }
+    glob := filepath.Join(l.Dir, namePatternGlob(l.Pattern))
+    matches, err := filepath.Glob(glob + ".log")
+    if err != nil {
+        return err
+    }
+    compressed, err := filepath.Glob(glob + ".log.gz")
+    if err != nil {
+        return err
+    }
+    matches = append(matches, compressed...)
+
+    type logFile struct {
+        path    string
+        modTime time.Time
+    }
+    var files []logFile
+    for _, path := range matches {
+        if path == l.File.Name() {
+            continue
+        }
+        if info, err := os.Stat(path); err == nil {
+            files = append(files, logFile{path, info.ModTime()})
+        }
+    }
+    sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+    // The current file counts towards Keep
+    for len(files) > l.Keep-1 {
+        if err := os.Remove(files[0].path); err != nil && !os.IsNotExist(err) {
+            return err
+        }
+        files = files[1:]
+    }
+    return nil
+}
+
+// Delivery states of a SentEvent
+const (
+    EventSent    = "sent"    // Written, no acknowledgment expected
+    EventPending = "pending" // Written, waiting for the server to acknowledge
+    EventAcked   = "acked"
+    EventFailed  = "failed"  // The server returned an error naming the event
+    EventTimeout = "timeout" // No acknowledgment within AckTimeout
+)
+
+// SentEvent tracks delivery of one client event
+type SentEvent struct {
+    ID     string
+    Type   string
+    Status string
+    Error  string
+    Sent   time.Time
+    Acked  time.Time
+    Timer  *time.Timer `json:"-"`
 }
 
 type LogEntry struct {
@@ -160,18 +1144,149 @@ type ChatClient struct {
     Metrics        *Metrics
     AudioBuffer    map[string]*AudioMessage
     AudioMutex     sync.Mutex
+    LastResponse   *AudioMessage          // Most recent assistant response, kept for /save
+    ServerBufferMs int64                  // Input buffer limit reported by the server, accessed atomically
+    PendingTurns   int64                  // Requested responses not yet done, accessed atomically
+    Watchdogs      map[string]*time.Timer // Stuck-response timers keyed like AudioBuffer
+    SessionID      string                 // Server session ID from session.created
+    WriteMutex     sync.Mutex             // Serializes writes to Conn
+    TurnTimer      *time.Timer            // Per-turn timeout, guarded by TurnMutex
+    TurnMutex      sync.Mutex
+    Console        *console.Console // Owns stdout so prompts and log lines don't interleave
+    Session        Session          // Session parameters most recently sent in session.update, guarded by SessionMutex
+    SessionMutex   sync.Mutex
+    EmptyRetried   atomic.Bool        // Set once the current turn has been retried for an empty transcript
+    ErrorRetried   atomic.Bool        // Set once the current turn has been retried after a server error
+    Capabilities   ServerCapabilities // Known server options, guarded by CapMutex
+    CapMutex       sync.Mutex
+    SessionReady   chan struct{} // Closed when session.created arrives
+    ReadyOnce      sync.Once
+    Dial           func() (*websocket.Conn, error) // Opens a new connection for reconnects
+    ConnMutex      sync.Mutex                      // Guards swapping Conn
+    History        *MessageRing                    // Recent text turns, listed by /history
+    Transcript     []ChatMessage                   // Every text turn, replayed after a reconnect, guarded by HistoryMutex
+    HistoryMutex   sync.Mutex
+    MicStop        chan struct{} // Closed to stop a /mic stream, guarded by MicMutex
+    MicMutex       sync.Mutex
+    Active         *ActiveResponse // Response currently streaming, guarded by AudioMutex
+    Cancelled      map[string]bool // Responses interrupted by barge-in, guarded by AudioMutex
+    Abandoned      map[string]bool // Responses given up on, whose late response.done is ignored, guarded by AudioMutex
+    Tools          []Tool          // Functions advertised in session.update
+    ToolFuncs      map[string]ToolFunc
+    ToolCalls      map[string]*sync.WaitGroup // Outstanding calls per response ID
+    ToolMutex      sync.Mutex
+    TurnDone       chan string // Receives the ID of each response that ends a turn
+    ConvFile       *os.File    // Combined session transcript, guarded by ConvMutex
+    ConvMutex      sync.Mutex
+    Captions       map[string][]subtitles.Delta // Transcript deltas per response item, guarded by AudioMutex
+    TurnFile       *os.File                     // Session JSONL transcript, guarded by ConvMutex
+    Started        time.Time                    // When the client was created, used to name session files
+    Turns          []TurnRecord                 // Conversation so far, guarded by ConvMutex
+    LiveKey        string                       // Response item whose caption is streaming, guarded by AudioMutex
+    EventSeq       int64                        // Last client event number, accessed atomically
+    Events         []*SentEvent                 // Recently sent events, guarded by EventMutex
+    EventMutex     sync.Mutex
+    Items          []string // Conversation item IDs, oldest first, guarded by ItemMutex
+    ReplyItems     []string // Items created since the user's last one, for /retry, guarded by ItemMutex
+    ItemMutex      sync.Mutex
+    Paced          []PacedSend // Sends waiting on rate limits, oldest first, guarded by PaceMutex
+    PaceMutex      sync.Mutex
+    Expires        time.Time     // When the server session expires, guarded by RenewMutex
+    RenewTimers    []*time.Timer // Expiry warning and renewal timers, guarded by RenewMutex
+    RenewMutex     sync.Mutex
+    Trace          atomic.Pointer[tracing.Turn] // Spans of the current turn, nil between turns
+    Requested      []*TurnMetrics               // Pending responses, oldest first, guarded by TurnMutex
+    AudioEnded     map[string]bool              // Response items whose response.audio.done arrived, guarded by AudioMutex
+    Positions      map[string]AudioPosition     // Indexes of the last audio delta per response, guarded by AudioMutex
+    ConnState      atomic.Value                 // Connection state for the status bar, a string
+    NextResponse   *ResponseOptions             // Options for the next response.create only, guarded by TurnMutex
+    TurnTag        string                       // Stamped on requested responses under TurnKey when set, guarded by TurnMutex
+    OnOutput       func(Output)                 // Receives session output as it arrives, on the receive routine; nil when unused
+    Log            atomic.Pointer[slog.Logger]  // Tagged with the session ID once it is known; nil uses slog's default
+    PerCaller      bool                         // Opened by a server for one of its callers, alongside others
+}
+
+// DefaultOOBInstructions frame /oob requests as questions about the session
+const DefaultOOBInstructions = "You are answering a side question about the conversation transcript " +
+    "below. Reply briefly in text. Your reply is not part of the conversation."
+
+// DefaultBaseURL is the OpenAI realtime endpoint. OPENAI_REALTIME_URL or
+// -url point the client at a proxy, mock or compatible server instead.
+const DefaultBaseURL = "wss://api.openai.com/v1/realtime"
+
+// RealtimeModel is asked for when the endpoint URL names no model
+const RealtimeModel = "gpt-4o-realtime-preview-2024-10-01"
+
+// RealtimeURL returns the WebSocket URL to dial for the realtime endpoint
+// baseURL, asking for RealtimeModel unless baseURL names a model itself
+func RealtimeURL(baseURL string) (*url.URL, error) {
+    endpoint, err := url.Parse(baseURL)
+    if err != nil {
+        return nil, fmt.Errorf("invalid endpoint URL %q: %w", baseURL, err)
+    }
+    if endpoint.Scheme != "ws" && endpoint.Scheme != "wss" {
+        return nil, fmt.Errorf("invalid endpoint URL %q: scheme must be ws or wss", baseURL)
+    }
+
+    query := endpoint.Query()
+    if query.Get("model") == "" {
+        query.Set("model", RealtimeModel)
+    }
+    endpoint.RawQuery = query.Encode()
+    return endpoint, nil
 }
 
 // Default configuration
 func DefaultConfig() ClientConfig {
     return ClientConfig{
-        ReadTimeout:     30 * time.Second,
-        WriteTimeout:    10 * time.Second,
-        PingInterval:    30 * time.Second,
-        MaxRetries:      3,
-        BufferSize:      100,
-        ShutdownTimeout: 5 * time.Second,
-        AudioOutputDir:  "audio_output",
+        BaseURL:               DefaultBaseURL,
+        ReadTimeout:           30 * time.Second,
+        WriteTimeout:          10 * time.Second,
+        PingInterval:          30 * time.Second,
+        MaxRetries:            3,
+        ReconnectBackoff:      time.Second,
+        BufferSize:            100,
+        ShutdownTimeout:       5 * time.Second,
+        AudioOutputDir:        "audio_output",
+        OutputGain:            1.0,
+        AutoSave:              true,
+        MaxBufferSeconds:      300,
+        StuckResponseTimeout:  30 * time.Second,
+        AckTimeout:            10 * time.Second,
+        TurnTimeout:           2 * time.Minute,
+        AudioURLTimeout:       30 * time.Second,
+        MaxAudioURLBytes:      50 * 1024 * 1024,
+        EmptyTranscriptPolicy: EmptyTranscriptPlaceholder,
+        ReplayHistory:         true,
+        ClientVAD:             true,
+        VADThreshold:          0.02,
+        VADSilence:            800 * time.Millisecond,
+        BargeIn:               true,
+        FFmpegPath:            "ffmpeg",
+        SplitOverlapSeconds:   1,
+        ConversationLog:       true,
+        ProtocolLog:           true,
+        SessionSummary:        true,
+        Subtitles:             true,
+        TurnLog:               true,
+        LiveCaptions:          true,
+        Progress:              true,
+        RateLimitReserve:      0.05,
+        OOBInstructions:       DefaultOOBInstructions,
+        ContextTokens:         128000,
+        PruneThreshold:        0.9,
+        Prices:                DefaultPrices,
+        RenewSession:          true,
+        ExpiryWarning:         2 * time.Minute,
+        LogLevel:              "info",
+        LogFormat:             "text",
+        LogMaxMB:              100,
+        LogMaxAge:             24 * time.Hour,
+        LogKeep:               10,
+        LogAudioSent:          LogAudioFull,
+        LogAudioReceived:      LogAudioFull,
+        LogNamePattern:        "Chat_{time}",
+        AudioNamePattern:      "audio_{time}",
     }
 }
 
@@ -182,31 +1297,278 @@ func (m *Metrics) RecordLatency(start time.Time) {
     m.Latencies = append(m.Latencies, time.Since(start))
 }
 
+// RecordTurn stores a finished response and its latency
+func (m *Metrics) RecordTurn(turn TurnMetrics) {
+    m.Mu.Lock()
+    defer m.Mu.Unlock()
+    m.Turns = append(m.Turns, turn)
+    m.Latencies = append(m.Latencies, turn.Done.Sub(turn.Requested))
+}
+
+// Export writes the per-turn metrics to path, as CSV if it ends in .csv
+// and as JSON otherwise
+func (m *Metrics) Export(path string) error {
+    m.Mu.Lock()
+    turns := append([]TurnMetrics(nil), m.Turns...)
+    m.Mu.Unlock()
+
+    file, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+
+    if strings.EqualFold(filepath.Ext(path), ".csv") {
+        err = writeTurnsCSV(file, turns)
+    } else {
+        encoder := json.NewEncoder(file)
+        encoder.SetIndent("", "  ")
+        err = encoder.Encode(turns)
+    }
+    if closeErr := file.Close(); err == nil {
+        err = closeErr
+    }
+    return err
+}
+
+// writeTurnsCSV writes one row per turn under a header row
+func writeTurnsCSV(w io.Writer, turns []TurnMetrics) error {
+    out := csv.NewWriter(w)
+    out.Write([]string{"response_id", "requested", "done", "status", "latency_ms", "first_audio_ms",
+        "audio_bytes", "input_tokens", "input_text_tokens", "input_audio_tokens", "cached_tokens",
+        "output_tokens", "output_text_tokens", "output_audio_tokens"})
+    for _, t := range turns {
+        u := t.Usage
+        out.Write([]string{
+            t.ResponseID,
+            t.Requested.Format(time.RFC3339Nano),
+            t.Done.Format(time.RFC3339Nano),
+            t.Status,
+            strconv.FormatInt(t.LatencyMs, 10),
+            strconv.FormatInt(t.FirstAudioMs, 10),
+            strconv.FormatInt(t.AudioBytes, 10),
+            strconv.Itoa(u.InputTokens),
+            strconv.Itoa(u.InputTokenDetails.TextTokens),
+            strconv.Itoa(u.InputTokenDetails.AudioTokens),
+            strconv.Itoa(u.InputTokenDetails.CachedTokens),
+            strconv.Itoa(u.OutputTokens),
+            strconv.Itoa(u.OutputTokenDetails.TextTokens),
+            strconv.Itoa(u.OutputTokenDetails.AudioTokens),
+        })
+    }
+    out.Flush()
+    return out.Error()
+}
+
+// RecordFirstAudio records how long a response took to start speaking
+func (m *Metrics) RecordFirstAudio(d time.Duration) {
+    m.Mu.Lock()
+    defer m.Mu.Unlock()
+    m.FirstAudio = append(m.FirstAudio, d)
+}
+
 func (m *Metrics) RecordError() {
     atomic.AddInt64(&m.Errors, 1)
 }
 
+// RecordServerError counts a server error event under its code
+func (m *Metrics) RecordServerError(code string) {
+    atomic.AddInt64(&m.Errors, 1)
+
+    m.Mu.Lock()
+    defer m.Mu.Unlock()
+    if m.ServerErrors == nil {
+        m.ServerErrors = make(map[string]int64)
+    }
+    m.ServerErrors[code]++
+}
+
+// RecordUnfinished counts a response that ended without completing
+func (m *Metrics) RecordUnfinished(status string) {
+    m.Mu.Lock()
+    defer m.Mu.Unlock()
+    if m.Unfinished == nil {
+        m.Unfinished = make(map[string]int64)
+    }
+    m.Unfinished[status]++
+}
+
+// RecordRateLimits stores the limits reported by the server
+func (m *Metrics) RecordRateLimits(limits []RateLimit) {
+    now := time.Now()
+
+    m.Mu.Lock()
+    defer m.Mu.Unlock()
+    if m.RateLimits == nil {
+        m.RateLimits = make(map[string]RateLimit)
+    }
+    for _, limit := range limits {
+        limit.Updated = now
+        m.RateLimits[limit.Name] = limit
+    }
+}
+
+// RateLimit returns the latest limit with the given name
+func (m *Metrics) RateLimit(name string) (RateLimit, bool) {
+    m.Mu.Lock()
+    defer m.Mu.Unlock()
+    limit, ok := m.RateLimits[name]
+    return limit, ok
+}
+
 func (m *Metrics) RecordAudioChunk() {
     atomic.AddInt64(&m.AudioChunks, 1)
 }
 
+// RecordUsage adds the usage reported by a response.done event
+func (m *Metrics) RecordUsage(u Usage) {
+    m.Mu.Lock()
+    defer m.Mu.Unlock()
+    m.Usage.Add(u)
+    m.Responses++
+}
+
+// UsageReport summarizes the tokens used so far and what they cost at prices
+func (m *Metrics) UsageReport(prices PriceTable) string {
+    m.Mu.Lock()
+    u := m.Usage
+    responses := m.Responses
+    m.Mu.Unlock()
+
+    in, out := u.InputTokenDetails, u.OutputTokenDetails
+    return fmt.Sprintf("Token usage over %d responses:\n"+
+        "  Input:  %d (text %d, audio %d, cached %d)\n"+
+        "  Output: %d (text %d, audio %d)\n"+
+        "  Estimated cost: $%.4f",
+        responses,
+        u.InputTokens, in.TextTokens, in.AudioTokens, in.CachedTokens,
+        u.OutputTokens, out.TextTokens, out.AudioTokens,
+        prices.Cost(u))
+}
+
+// Percentile returns the latency below which p percent of turns finished,
+// using the nearest-rank method
+func (m *Metrics) Percentile(p float64) (time.Duration, bool) {
+    m.Mu.Lock()
+    sorted := append([]time.Duration(nil), m.Latencies...)
+    m.Mu.Unlock()
+    return percentile(sorted, p)
+}
+
+// percentile sorts latencies in place and picks the nearest-rank value
+func percentile(latencies []time.Duration, p float64) (time.Duration, bool) {
+    if len(latencies) == 0 {
+        return 0, false
+    }
+    sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+    rank := int(math.Ceil(p / 100 * float64(len(latencies))))
+    if rank < 1 {
+        rank = 1
+    }
+    if rank > len(latencies) {
+        rank = len(latencies)
+    }
+    return latencies[rank-1], true
+}
+
+// Report summarizes the session metrics for /stats and the shutdown summary
+func (m *Metrics) Report() string {
+    m.Mu.Lock()
+    latencies := append([]time.Duration(nil), m.Latencies...)
+    firstAudio := append([]time.Duration(nil), m.FirstAudio...)
+    serverErrors := countsString(m.ServerErrors)
+    unfinished := countsString(m.Unfinished)
+    m.Mu.Unlock()
+
+    var b strings.Builder
+    b.WriteString("Session metrics:\n")
+    fmt.Fprintf(&b, "  Turns:        %d", len(latencies))
+    if len(latencies) > 0 {
+        fmt.Fprintf(&b, " (latency %s)", percentiles(latencies))
+    }
+    if len(firstAudio) > 0 {
+        fmt.Fprintf(&b, "\n  First audio:  %s", percentiles(firstAudio))
+    }
+    fmt.Fprintf(&b, "\n  Messages:     %d sent, %d received\n",
+        atomic.LoadInt64(&m.MessagesSent), atomic.LoadInt64(&m.MessagesReceived))
+    fmt.Fprintf(&b, "  Audio chunks: %d (late %d, out of order %d, index gaps %d, channel stalls %d)\n",
+        atomic.LoadInt64(&m.AudioChunks), atomic.LoadInt64(&m.LateChunks), atomic.LoadInt64(&m.OutOfOrder),
+        atomic.LoadInt64(&m.IndexGaps), atomic.LoadInt64(&m.ChannelStalls))
+    fmt.Fprintf(&b, "  Errors:       %d", atomic.LoadInt64(&m.Errors))
+    if serverErrors != "" {
+        fmt.Fprintf(&b, " (server: %s)", serverErrors)
+    }
+    if unfinished != "" {
+        fmt.Fprintf(&b, "\n  Unfinished:   %s", unfinished)
+    }
+    return b.String()
+}
+
+// percentiles formats the p50, p90 and p99 of latencies, sorting them
+func percentiles(latencies []time.Duration) string {
+    parts := make([]string, 0, 3)
+    for _, p := range []float64{50, 90, 99} {
+        v, _ := percentile(latencies, p)
+        parts = append(parts, fmt.Sprintf("p%g %v", p, v.Round(time.Millisecond)))
+    }
+    return strings.Join(parts, ", ")
+}
+
+// countsString formats counts as "key n" pairs in key order
+func countsString(counts map[string]int64) string {
+    keys := make([]string, 0, len(counts))
+    for key := range counts {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    parts := make([]string, len(keys))
+    for i, key := range keys {
+        parts[i] = fmt.Sprintf("%s %d", key, counts[key])
+    }
+    return strings.Join(parts, ", ")
+}
+
 func (l *Logger) Log(direction, msgType string, content interface{}) {
-    l.Mu.Lock()
-    defer l.Mu.Unlock()
+    if err := l.Entry(direction, msgType, content); err != nil {
+        slog.Error("writing to log", "type", msgType, "err", err)
+    }
+}
 
+// Entry encodes one log entry and queues it for the writer. It returns
+// encoding errors instead of logging them, for callers that are themselves
+// log sinks; write errors are reported by the writer.
+func (l *Logger) Entry(direction, msgType string, content interface{}) error {
     entry := LogEntry{
         Timestamp: time.Now().Format(time.RFC3339Nano),
         Direction: direction,
         Type:      msgType,
-        RawJSON:   content,
+        RawJSON:   redactAudio(msgType, content, l.AudioPolicy[direction]),
+    }
+    data, err := json.Marshal(entry)
+    if err != nil {
+        return err
     }
 
-    if err := l.Encoder.Encode(entry); err != nil {
-        log.Printf("Error writing to log: %v", err)
+    l.Mu.Lock()
+    defer l.Mu.Unlock()
+    if l.queue == nil || l.closed {
+        return nil
     }
-    l.File.Sync()
+    l.queue <- append(data, '\n')
+    return nil
 }
 
+// Close writes out every queued entry and closes the log file; later
+// entries are dropped
 func (l *Logger) Close() error {
-    return l.File.Close()
+    l.Mu.Lock()
+    if l.queue == nil || l.closed {
+        l.Mu.Unlock()
+        return nil
+    }
+    l.closed = true
+    close(l.queue)
+    l.Mu.Unlock()
+
+    <-l.done
+    return l.closeFile()
 }
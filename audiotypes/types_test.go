@@ -0,0 +1,123 @@
+package audiotypes
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestRollingHash(t *testing.T) {
+    chunks := [][]byte{[]byte("first chunk"), {}, []byte("second"), make([]byte, 4800)}
+
+    a, b := NewRollingHash(), NewRollingHash()
+    var whole []byte
+    for i, chunk := range chunks {
+        chunkSum, rolling := a.Add(chunk)
+        otherChunk, otherRolling := b.Add(chunk)
+        if chunkSum != otherChunk || rolling != otherRolling {
+            t.Fatalf("chunk %d: the same audio hashed differently", i)
+        }
+
+        sum := sha256.Sum256(chunk)
+        if chunkSum != hex.EncodeToString(sum[:]) {
+            t.Errorf("chunk %d: digest %s is not the chunk's SHA-256", i, chunkSum)
+        }
+        // The rolling digest is of everything so far, as one stream
+        whole = append(whole, chunk...)
+        sum = sha256.Sum256(whole)
+        if rolling != hex.EncodeToString(sum[:]) || a.Sum() != rolling {
+            t.Errorf("chunk %d: rolling digest %s is not the SHA-256 of the stream", i, rolling)
+        }
+    }
+    if a.Chunks != len(chunks) || a.Bytes != int64(len(whole)) {
+        t.Errorf("counted %d chunks, %d bytes; want %d, %d", a.Chunks, a.Bytes, len(chunks), len(whole))
+    }
+
+    // Chunk boundaries don't change the rolling digest, only the chunk ones
+    c := NewRollingHash()
+    c.Add(whole)
+    if c.Sum() != a.Sum() {
+        t.Error("one chunk of the whole stream hashed differently from its parts")
+    }
+}
+
+func TestRollingHashEmpty(t *testing.T) {
+    // The digest of nothing at all
+    const empty = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+    if got := NewRollingHash().Sum(); got != empty {
+        t.Errorf("got %s, want %s", got, empty)
+    }
+}
+
+func TestRealtimeURL(t *testing.T) {
+    tests := []struct {
+        base string
+        want string
+    }{
+        {DefaultBaseURL, DefaultBaseURL + "?model=" + RealtimeModel},
+        {"wss://example.com/v1/realtime?model=gpt-4o-mini-realtime-preview", "wss://example.com/v1/realtime?model=gpt-4o-mini-realtime-preview"},
+        {"ws://127.0.0.1:8080/", "ws://127.0.0.1:8080/?model=" + RealtimeModel},
+        {"wss://example.com/realtime?deployment=test", "wss://example.com/realtime?deployment=test&model=" + RealtimeModel},
+    }
+    for _, tt := range tests {
+        got, err := RealtimeURL(tt.base)
+        if err != nil {
+            t.Errorf("%s: %v", tt.base, err)
+            continue
+        }
+        if got.String() != tt.want {
+            t.Errorf("%s: got %s, want %s", tt.base, got, tt.want)
+        }
+    }
+
+    for _, base := range []string{"https://api.openai.com/v1/realtime", "api.openai.com", "ws://[::1"} {
+        if _, err := RealtimeURL(base); err == nil {
+            t.Errorf("%s: accepted", base)
+        }
+    }
+}
+
+func TestClientConfigJSON(t *testing.T) {
+    config := DefaultConfig()
+    config.TurnTimeout = 90 * time.Second
+    config.SummaryInterval = 1500 * time.Millisecond
+
+    data, err := json.Marshal(config)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, want := range []string{`"turn_timeout":"1m30s"`, `"summary_interval":"1.5s"`, `"log_max_age":"24h0m0s"`, `"audio_output_dir":"audio_output"`} {
+        if !strings.Contains(string(data), want) {
+            t.Errorf("encoded config lacks %s: %s", want, data)
+        }
+    }
+
+    var decoded ClientConfig
+    if err := json.Unmarshal(data, &decoded); err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(decoded, config) {
+        t.Errorf("round trip changed the config:\ngot  %+v\nwant %+v", decoded, config)
+    }
+
+    // Fields left out keep their values
+    partial := DefaultConfig()
+    if err := json.Unmarshal([]byte(`{"read_timeout": "5s", "max_retries": 7}`), &partial); err != nil {
+        t.Fatal(err)
+    }
+    want := DefaultConfig()
+    want.ReadTimeout, want.MaxRetries = 5*time.Second, 7
+    if !reflect.DeepEqual(partial, want) {
+        t.Errorf("partial config:\ngot  %+v\nwant %+v", partial, want)
+    }
+
+    for _, bad := range []string{`{"turn_timeout": 120000000000}`, `{"turn_timeout": "two minutes"}`} {
+        if err := json.Unmarshal([]byte(bad), &decoded); err == nil {
+            t.Errorf("%s: accepted", bad)
+        }
+    }
+}
@@ -0,0 +1,315 @@
+package console
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+    "sync/atomic"
+)
+
+const (
+    writeOutput = iota
+    showPrompt
+    inputRead
+    streamOutput
+    endStream
+    editKey
+    setActivity
+)
+
+type request struct {
+    kind int
+    out  io.Writer
+    text string
+    done chan struct{}
+    edit *editResult // Filled in for editKey
+}
+
+// editResult reports what a key did to the line being edited
+type editResult struct {
+    line    string
+    entered bool // The user pressed enter; line is complete
+    eof     bool // The user pressed ctrl+d on an empty line
+}
+
+// Completer completes the line being edited when the user presses tab. It
+// returns the part of line that stays as it is and the alternatives for
+// the rest; a single alternative is filled in, several are listed.
+type Completer func(line string) (head string, alternatives []string)
+
+// Console serializes everything written to the terminal through a single
+// goroutine, so log lines from background routines never split the input
+// prompt. Output interjected while the prompt is showing clears the prompt
+// line, is written whole, and the prompt is drawn again underneath.
+//
+// Streamed output, such as live captions, is written as it arrives without
+// a trailing newline; the prompt stays hidden until the stream ends.
+//
+// When both ends are a terminal, the console edits the input line itself,
+// so that text typed but not yet submitted is redrawn along with the
+// prompt and tab can complete it.
+//
+// StartTUI hands the terminal to a full-screen interface instead, which
+// keeps log output in a pane of its own.
+type Console struct {
+    out       io.Writer
+    errOut    io.Writer
+    prompt    string
+    terminal  bool
+    editing   atomic.Bool // Cleared by ReadLine if the terminal can't be taken over
+    color     atomic.Bool
+    requests  chan request
+    in        *bufio.Reader
+    completer Completer
+    tui       atomic.Pointer[tui] // Set by StartTUI
+}
+
+// New starts a console writing regular output to out and log output to errOut.
+func New(out, errOut io.Writer, prompt string) *Console {
+    c := &Console{
+        out:      out,
+        errOut:   errOut,
+        prompt:   prompt,
+        terminal: isTerminal(out),
+        requests: make(chan request),
+        in:       bufio.NewReader(os.Stdin),
+    }
+    c.editing.Store(c.terminal && isTerminal(os.Stdin))
+    c.color.Store(c.terminal && os.Getenv("NO_COLOR") == "")
+    go c.run()
+    return c
+}
+
+func (c *Console) run() {
+    waiting := false   // the user is being asked for input
+    shown := false     // the prompt line is the last thing on the current line
+    streaming := false // a stream is open
+    partial := false   // streamed text is the last thing on the current line
+    var line []rune    // input typed so far, when the console edits it
+    activity := ""     // indicator shown ahead of the prompt
+
+    // drawPrompt draws the prompt line: the activity indicator, if any,
+    // followed by the prompt and input when the user is being asked. The
+    // indicator is left out while the terminal echoes input itself, since
+    // redrawing the line would hide what the user typed.
+    drawPrompt := func() {
+        text := ""
+        if activity != "" && (c.editing.Load() || !waiting) {
+            text = c.Style(Notice, activity) + " "
+        }
+        if waiting {
+            text += c.styledPrompt() + string(line)
+        }
+        if text != "" {
+            io.WriteString(c.out, text)
+            shown = true
+        }
+    }
+    clearPrompt := func() {
+        if shown {
+            if c.terminal {
+                io.WriteString(c.out, "\r\033[K")
+            } else {
+                io.WriteString(c.out, "\n")
+            }
+            shown = false
+        }
+    }
+
+    for req := range c.requests {
+        switch req.kind {
+        case writeOutput:
+            clearPrompt()
+            if partial {
+                io.WriteString(c.out, "\n")
+                partial = false
+            }
+            text := req.text
+            if !strings.HasSuffix(text, "\n") {
+                text += "\n"
+            }
+            io.WriteString(req.out, text)
+            if !streaming {
+                drawPrompt()
+            }
+        case streamOutput:
+            clearPrompt()
+            io.WriteString(c.out, req.text)
+            streaming = true
+            partial = !strings.HasSuffix(req.text, "\n")
+        case endStream:
+            if partial {
+                io.WriteString(c.out, req.text+"\n")
+            }
+            streaming, partial = false, false
+            if !shown {
+                drawPrompt()
+            }
+        case showPrompt:
+            waiting = true
+            if !streaming && (!shown || activity != "") {
+                clearPrompt()
+                drawPrompt()
+            }
+        case editKey:
+            redraw := func() {
+                clearPrompt()
+                drawPrompt()
+            }
+            c.editLine(&line, req.text, shown, redraw, req.edit)
+            if req.edit.entered {
+                waiting = false
+                shown = false
+                if activity != "" {
+                    drawPrompt()
+                }
+            }
+        case setActivity:
+            if req.text == activity {
+                break
+            }
+            activity = req.text
+            if !streaming {
+                clearPrompt()
+                drawPrompt()
+            }
+        case inputRead:
+            // The user's newline has already moved the cursor off the prompt
+            waiting = false
+            shown = false
+            if activity != "" && !streaming {
+                drawPrompt()
+            }
+        }
+        close(req.done)
+    }
+}
+
+func (c *Console) send(kind int, out io.Writer, text string) {
+    if t := c.tui.Load(); t != nil && t.running() {
+        t.program.Send(outputMsg{kind: kind, log: out == c.errOut, text: text})
+        return
+    }
+    done := make(chan struct{})
+    c.requests <- request{kind: kind, out: out, text: text, done: done}
+    <-done
+}
+
+// Printf writes a formatted block of output as a single unit.
+func (c *Console) Printf(format string, a ...interface{}) {
+    c.send(writeOutput, c.out, fmt.Sprintf(format, a...))
+}
+
+// Println writes its operands as a single line of output.
+func (c *Console) Println(a ...interface{}) {
+    c.send(writeOutput, c.out, fmt.Sprintln(a...))
+}
+
+// Stream writes text immediately, continuing the current stream.
+func (c *Console) Stream(text string) {
+    c.send(streamOutput, c.out, text)
+}
+
+// EndStream finishes the current stream, appending suffix to its last line,
+// and redraws the prompt if input is pending.
+func (c *Console) EndStream(suffix string) {
+    c.send(endStream, c.out, suffix)
+}
+
+// Prompt shows the input prompt and keeps it on screen until InputRead.
+func (c *Console) Prompt() {
+    c.send(showPrompt, nil, "")
+}
+
+// InputRead records that the user submitted a line, so the prompt is no
+// longer on screen.
+func (c *Console) InputRead() {
+    c.send(inputRead, nil, "")
+}
+
+// SetCompleter sets the function tab completes input with. It must be
+// called before the first ReadLine.
+func (c *Console) SetCompleter(complete Completer) {
+    c.completer = complete
+}
+
+// ReadLine returns the next line the user entered, including its newline.
+// It returns io.EOF once input ends, which in the full-screen interface is
+// when the user presses ctrl+c or ctrl+d.
+func (c *Console) ReadLine() (string, error) {
+    if t := c.tui.Load(); t != nil {
+        line, ok := <-t.lines
+        if !ok {
+            return "", io.EOF
+        }
+        return line, nil
+    }
+    if c.editing.Load() {
+        restore, err := setCbreak(os.Stdin)
+        if err == nil {
+            defer restore()
+            return c.readEdited()
+        }
+        // Without control of the terminal, fall back to its own line editing
+        c.editing.Store(false)
+    }
+    return c.in.ReadString('\n')
+}
+
+// SetActivity shows a short indicator, such as a spinner, ahead of the
+// prompt until it is set to "". Only a terminal shows it; in the
+// full-screen interface it joins the status bar.
+func (c *Console) SetActivity(text string) {
+    if t := c.tui.Load(); t != nil && t.running() {
+        t.program.Send(activityMsg(text))
+        return
+    }
+    if c.terminal {
+        c.send(setActivity, nil, text)
+    }
+}
+
+// SetStatus shows text in the status bar of the full-screen interface; a
+// plain console has none and ignores it.
+func (c *Console) SetStatus(text string) {
+    if t := c.tui.Load(); t != nil && t.running() {
+        t.program.Send(statusMsg(text))
+    }
+}
+
+// Close restores the terminal if the full-screen interface has it, so that
+// later output, such as a session summary, stays on screen.
+func (c *Console) Close() {
+    if t := c.tui.Load(); t != nil {
+        t.close()
+    }
+}
+
+// LogWriter returns a writer suitable for log.SetOutput that routes log
+// lines to errOut through the console.
+func (c *Console) LogWriter() io.Writer {
+    return logWriter{c}
+}
+
+type logWriter struct {
+    c *Console
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+    w.c.send(writeOutput, w.c.errOut, string(p))
+    return len(p), nil
+}
+
+func isTerminal(w io.Writer) bool {
+    file, ok := w.(*os.File)
+    if !ok {
+        return false
+    }
+    info, err := file.Stat()
+    if err != nil {
+        return false
+    }
+    return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,101 @@
+package console
+
+import (
+    "bytes"
+    "fmt"
+    "strings"
+    "sync"
+    "testing"
+)
+
+// Writes from many goroutines reach the shared writer whole and one at a
+// time; bytes.Buffer isn't safe for concurrent use, so go test -race also
+// catches any write that bypasses the coordinator
+func TestConsoleSerializesWrites(t *testing.T) {
+    var out bytes.Buffer
+    c := New(&out, &out, "You: ")
+    log := c.LogWriter()
+
+    const writers, lines = 10, 50
+    var wg sync.WaitGroup
+    for w := 0; w < writers; w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := 0; i < lines; i++ {
+                if i%2 == 0 {
+                    c.Printf("writer %d line %d", w, i)
+                } else {
+                    fmt.Fprintf(log, "writer %d line %d\n", w, i)
+                }
+            }
+        }()
+    }
+    wg.Wait()
+
+    seen := map[string]bool{}
+    for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+        var w, i int
+        if n, err := fmt.Sscanf(line, "writer %d line %d", &w, &i); n != 2 || err != nil || seen[line] {
+            t.Fatalf("garbled or repeated line %q", line)
+        }
+        seen[line] = true
+    }
+    if len(seen) != writers*lines {
+        t.Errorf("got %d lines, want %d", len(seen), writers*lines)
+    }
+}
+
+func TestConsoleRedrawsPrompt(t *testing.T) {
+    tests := []struct {
+        name string
+        run  func(c *Console)
+        want string
+    }{
+        {
+            name: "output under the prompt",
+            run: func(c *Console) {
+                c.Prompt()
+                c.Println("log line")
+            },
+            want: "You: \nlog line\nYou: ",
+        },
+        {
+            name: "prompt shown once",
+            run: func(c *Console) {
+                c.Prompt()
+                c.Prompt()
+            },
+            want: "You: ",
+        },
+        {
+            name: "no prompt after input",
+            run: func(c *Console) {
+                c.Prompt()
+                c.InputRead()
+                c.Printf("reply")
+            },
+            want: "You: reply\n",
+        },
+        {
+            name: "stream holds the prompt back",
+            run: func(c *Console) {
+                c.Prompt()
+                c.Stream("Hel")
+                c.Stream("lo")
+                c.Println("log line")
+                c.Stream("again")
+                c.EndStream(" [done]")
+            },
+            want: "You: \nHello\nlog line\nagain [done]\nYou: ",
+        },
+    }
+    for _, tt := range tests {
+        var out bytes.Buffer
+        c := New(&out, &out, "You: ")
+        tt.run(c)
+        if out.String() != tt.want {
+            t.Errorf("%s: got %q, want %q", tt.name, out.String(), tt.want)
+        }
+    }
+}
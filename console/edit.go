@@ -0,0 +1,119 @@
+package console
+
+import (
+    "io"
+    "strings"
+    "unicode/utf8"
+)
+
+// readEdited reads keys until the user submits a line, handing each to the
+// console goroutine, which keeps the line and echoes it. The terminal must
+// already be in cbreak mode.
+func (c *Console) readEdited() (string, error) {
+    for {
+        r, _, err := c.in.ReadRune()
+        if err != nil {
+            return "", err
+        }
+        if r == '\033' {
+            // Arrow and function keys send escape sequences; the editor
+            // has no cursor movement, so they are dropped whole
+            c.skipEscape()
+            continue
+        }
+
+        result := &editResult{}
+        done := make(chan struct{})
+        c.requests <- request{kind: editKey, text: string(r), done: done, edit: result}
+        <-done
+        switch {
+        case result.eof:
+            return "", io.EOF
+        case result.entered:
+            return result.line + "\n", nil
+        }
+    }
+}
+
+// skipEscape discards the rest of an escape sequence such as "\033[A"
+func (c *Console) skipEscape() {
+    r, _, err := c.in.ReadRune()
+    if err != nil || (r != '[' && r != 'O') {
+        return
+    }
+    for {
+        r, _, err := c.in.ReadRune()
+        if err != nil || (r >= 0x40 && r <= 0x7e) {
+            return
+        }
+    }
+}
+
+// editLine applies one key to the line being edited. shown says whether
+// the prompt is on screen, in which case the change is echoed, or the
+// prompt line drawn again with redraw; otherwise the change appears when
+// the prompt is next drawn.
+func (c *Console) editLine(line *[]rune, key string, shown bool, redraw func(), result *editResult) {
+    echo := func(s string) {
+        if shown {
+            io.WriteString(c.out, s)
+        }
+    }
+
+    switch r := []rune(key)[0]; {
+    case r == '\r' || r == '\n':
+        io.WriteString(c.out, "\n")
+        result.line, result.entered = string(*line), true
+        *line = nil
+    case r == 0x04: // ctrl+d
+        result.eof = len(*line) == 0
+    case r == 0x7f || r == '\b':
+        if len(*line) > 0 {
+            *line = (*line)[:len(*line)-1]
+            echo("\b \b")
+        }
+    case r == 0x15: // ctrl+u
+        *line = nil
+        if shown {
+            redraw()
+        }
+    case r == '\t':
+        c.complete(line, shown, redraw)
+    case r >= ' ':
+        *line = append(*line, r)
+        echo(key)
+    }
+}
+
+// complete fills in as much of the line as every completion shares, and
+// lists the completions when that adds nothing
+func (c *Console) complete(line *[]rune, shown bool, redraw func()) {
+    if c.completer == nil {
+        return
+    }
+    head, alternatives := c.completer(string(*line))
+    if len(alternatives) == 0 {
+        return
+    }
+
+    common := alternatives[0]
+    for _, alt := range alternatives[1:] {
+        for !strings.HasPrefix(alt, common) {
+            _, size := utf8.DecodeLastRuneInString(common)
+            common = common[:len(common)-size]
+        }
+    }
+    completed := head + common
+    if len(alternatives) == 1 && !strings.HasSuffix(completed, "/") {
+        completed += " "
+    }
+
+    if completed != string(*line) {
+        *line = []rune(completed)
+    } else if shown {
+        io.WriteString(c.out, "\n"+strings.Join(alternatives, "  ")+"\n")
+    }
+    if shown {
+        redraw()
+    }
+}
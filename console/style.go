@@ -0,0 +1,44 @@
+package console
+
+// Role is the kind of text being written, which decides how it is styled
+type Role int
+
+const (
+    User       Role = iota // The prompt and the user's own input
+    Assistant              // The assistant's replies
+    Transcript             // Transcriptions of the user's speech
+    Notice                 // Messages from the client about the session
+    Error                  // Failures
+)
+
+// roleStyles are the ANSI escape sequences each role is written in
+var roleStyles = map[Role]string{
+    User:       "\033[1;32m",
+    Assistant:  "\033[36m",
+    Transcript: "\033[3m",
+    Notice:     "\033[33m",
+    Error:      "\033[1;31m",
+}
+
+const ansiReset = "\033[0m"
+
+// Style wraps s in the style for role when the console is colored. Each
+// styled piece resets its attributes, so text written after it, such as a
+// redrawn prompt, never inherits them.
+func (c *Console) Style(role Role, s string) string {
+    if !c.color.Load() || s == "" {
+        return s
+    }
+    return roleStyles[role] + s + ansiReset
+}
+
+// SetColor turns colored output on or off. It starts on when writing to a
+// terminal and NO_COLOR is not set.
+func (c *Console) SetColor(on bool) {
+    c.color.Store(on)
+}
+
+// styledPrompt is the prompt as it is drawn
+func (c *Console) styledPrompt() string {
+    return c.Style(User, c.prompt)
+}
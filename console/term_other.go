@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package console
+
+import (
+    "errors"
+    "os"
+)
+
+// setCbreak is unsupported here, so the terminal keeps its own line editing
+func setCbreak(f *os.File) (func(), error) {
+    return nil, errors.New("terminal modes not supported on this platform")
+}
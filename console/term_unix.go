@@ -0,0 +1,27 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package console
+
+import (
+    "os"
+
+    "golang.org/x/sys/unix"
+)
+
+// setCbreak turns off the terminal's own line editing and echo, leaving
+// signals such as ctrl+c alone, and returns a function that restores it
+func setCbreak(f *os.File) (func(), error) {
+    fd := int(f.Fd())
+    saved, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+    if err != nil {
+        return nil, err
+    }
+    cbreak := *saved
+    cbreak.Lflag &^= unix.ICANON | unix.ECHO
+    cbreak.Cc[unix.VMIN] = 1
+    cbreak.Cc[unix.VTIME] = 0
+    if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &cbreak); err != nil {
+        return nil, err
+    }
+    return func() { unix.IoctlSetTermios(fd, ioctlSetTermios, saved) }, nil
+}
@@ -0,0 +1,266 @@
+package console
+
+import (
+    "strconv"
+    "strings"
+
+    "github.com/charmbracelet/bubbles/textinput"
+    "github.com/charmbracelet/bubbles/viewport"
+    tea "github.com/charmbracelet/bubbletea"
+    "github.com/charmbracelet/lipgloss"
+)
+
+// maxEventLines caps the event pane so long sessions don't grow it forever
+const maxEventLines = 1000
+
+// tui runs the full-screen interface: a scrollable conversation pane, a
+// collapsible pane of log output, a status bar and an input line
+type tui struct {
+    program *tea.Program
+    lines   chan string   // Submitted input, closed when the user quits
+    done    chan struct{} // Closed once the program has restored the terminal
+}
+
+// outputMsg carries a console request into the program
+type outputMsg struct {
+    kind int
+    log  bool
+    text string
+}
+
+// statusMsg replaces the status bar text
+type statusMsg string
+
+// activityMsg replaces the activity indicator shown after the status
+type activityMsg string
+
+// StartTUI takes over the terminal with a full-screen interface. Log
+// output goes to its own pane, out of the way of the conversation, and
+// input is read with ReadLine. Once Close restores the terminal, output is
+// written as before.
+func (c *Console) StartTUI() {
+    input := textinput.New()
+    input.Prompt = c.prompt
+    if c.color.Load() {
+        input.PromptStyle = promptStyle
+    }
+    input.Focus()
+
+    t := &tui{
+        lines: make(chan string, 16),
+        done:  make(chan struct{}),
+    }
+    t.program = tea.NewProgram(&tuiModel{input: input, lines: t.lines}, tea.WithAltScreen())
+    go func() {
+        defer close(t.done)
+        t.program.Run()
+    }()
+    c.tui.Store(t)
+}
+
+// running reports whether the program still owns the terminal
+func (t *tui) running() bool {
+    select {
+    case <-t.done:
+        return false
+    default:
+        return true
+    }
+}
+
+func (t *tui) close() {
+    t.program.Quit()
+    <-t.done
+}
+
+type tuiModel struct {
+    width, height int
+    conversation  string // Everything written as regular output
+    partial       bool   // A stream left its last line unfinished
+    convView      viewport.Model
+    events        []string
+    eventView     viewport.Model
+    showEvents    bool
+    eventFocus    bool // Page keys scroll the event pane instead of the conversation
+    unseen        int  // Log lines written while the event pane was hidden
+    status        string
+    activity      string
+    input         textinput.Model
+    lines         chan string
+    quitting      bool
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+    return textinput.Blink
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+    switch msg := msg.(type) {
+    case tea.WindowSizeMsg:
+        m.width, m.height = msg.Width, msg.Height
+        m.layout()
+        return m, nil
+
+    case tea.KeyMsg:
+        switch msg.String() {
+        case "ctrl+c", "ctrl+d":
+            // Ends input like EOF on a plain terminal, so the client shuts
+            // down as it does for .quit
+            if !m.quitting {
+                m.quitting = true
+                close(m.lines)
+            }
+            return m, nil
+        case "enter":
+            line := m.input.Value()
+            m.input.Reset()
+            if m.quitting {
+                return m, nil
+            }
+            m.appendConversation(m.input.PromptStyle.Render(m.input.Prompt) + line + "\n")
+            select {
+            case m.lines <- line + "\n":
+            default:
+                m.appendEvents("Input dropped: still handling earlier input\n")
+            }
+            return m, nil
+        case "ctrl+e":
+            m.showEvents = !m.showEvents
+            m.eventFocus = m.eventFocus && m.showEvents
+            m.unseen = 0
+            m.layout()
+            return m, nil
+        case "tab":
+            m.eventFocus = m.showEvents && !m.eventFocus
+            return m, nil
+        case "pgup", "pgdown":
+            var cmd tea.Cmd
+            if m.eventFocus {
+                m.eventView, cmd = m.eventView.Update(msg)
+            } else {
+                m.convView, cmd = m.convView.Update(msg)
+            }
+            return m, cmd
+        }
+
+    case outputMsg:
+        switch msg.kind {
+        case writeOutput:
+            text := msg.text
+            if !strings.HasSuffix(text, "\n") {
+                text += "\n"
+            }
+            if msg.log {
+                m.appendEvents(text)
+                break
+            }
+            if m.partial {
+                text = "\n" + text
+                m.partial = false
+            }
+            m.appendConversation(text)
+        case streamOutput:
+            m.appendConversation(msg.text)
+            m.partial = !strings.HasSuffix(msg.text, "\n")
+        case endStream:
+            if m.partial {
+                m.appendConversation(msg.text + "\n")
+            }
+            m.partial = false
+        }
+        return m, nil
+
+    case statusMsg:
+        m.status = string(msg)
+        return m, nil
+
+    case activityMsg:
+        m.activity = string(msg)
+        return m, nil
+    }
+
+    var cmd tea.Cmd
+    m.input, cmd = m.input.Update(msg)
+    return m, cmd
+}
+
+func (m *tuiModel) appendConversation(text string) {
+    m.conversation += text
+    m.refresh(&m.convView, m.conversation)
+}
+
+func (m *tuiModel) appendEvents(text string) {
+    lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+    m.events = append(m.events, lines...)
+    if len(m.events) > maxEventLines {
+        m.events = m.events[len(m.events)-maxEventLines:]
+    }
+    if !m.showEvents {
+        m.unseen += len(lines)
+    }
+    m.refresh(&m.eventView, strings.Join(m.events, "\n"))
+}
+
+// refresh rewraps a pane's content, following new output unless the user
+// has scrolled back
+func (m *tuiModel) refresh(view *viewport.Model, content string) {
+    follow := view.AtBottom()
+    if m.width > 0 {
+        content = lipgloss.NewStyle().Width(m.width).Render(content)
+    }
+    view.SetContent(content)
+    if follow {
+        view.GotoBottom()
+    }
+}
+
+// layout sizes the panes: the event pane takes a third of the screen when
+// shown, and the status bar and input line one row each
+func (m *tuiModel) layout() {
+    rest := m.height - 2
+    events := 0
+    if m.showEvents {
+        events = rest / 3
+        rest -= events + 1 // The pane's title row
+    }
+    m.convView.Width, m.convView.Height = m.width, max(rest, 1)
+    m.eventView.Width, m.eventView.Height = m.width, max(events, 1)
+    m.input.Width = m.width - len(m.input.Prompt) - 1
+    m.refresh(&m.convView, m.conversation)
+    m.refresh(&m.eventView, strings.Join(m.events, "\n"))
+}
+
+var (
+    titleStyle  = lipgloss.NewStyle().Bold(true).Faint(true)
+    statusStyle = lipgloss.NewStyle().Reverse(true)
+    promptStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("2"))
+)
+
+func (m *tuiModel) View() string {
+    if m.width == 0 {
+        return ""
+    }
+    var b strings.Builder
+    b.WriteString(m.convView.View() + "\n")
+    if m.showEvents {
+        title := "Events (ctrl+e hide, tab scroll)"
+        if m.eventFocus {
+            title = "Events (ctrl+e hide, pgup/pgdown scroll, tab back)"
+        }
+        b.WriteString(titleStyle.Render(title) + "\n")
+        b.WriteString(m.eventView.View() + "\n")
+    }
+
+    hint := "ctrl+e events"
+    if m.unseen > 0 {
+        hint = "ctrl+e events (" + strconv.Itoa(m.unseen) + " new)"
+    }
+    status := m.status
+    if m.activity != "" {
+        status += " | " + m.activity
+    }
+    gap := m.width - lipgloss.Width(status) - lipgloss.Width(hint) - 2
+    b.WriteString(statusStyle.Render(" "+status+strings.Repeat(" ", max(gap, 1))+hint+" ") + "\n")
+    b.WriteString(m.input.View())
+    return b.String()
+}
@@ -0,0 +1,1814 @@
+package main
+
+import (
+    "archive/zip"
+    "bytes"
+    "encoding/base64"
+    "encoding/binary"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log/slog"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "geppetoaudio/audiotypes"
+    "geppetoaudio/console"
+
+    "github.com/gorilla/websocket"
+)
+
+// riffChunk encodes one RIFF chunk, padding odd bodies to an even length
+func riffChunk(id string, body []byte) []byte {
+    b := make([]byte, 8, 8+len(body)+1)
+    copy(b, id)
+    binary.LittleEndian.PutUint32(b[4:], uint32(len(body)))
+    b = append(b, body...)
+    if len(body)&1 == 1 {
+        b = append(b, 0)
+    }
+    return b
+}
+
+// fmtBody is a PCM fmt chunk body
+func fmtBody(format, channels uint16, rate uint32, bits uint16) []byte {
+    b := make([]byte, 16)
+    binary.LittleEndian.PutUint16(b[0:], format)
+    binary.LittleEndian.PutUint16(b[2:], channels)
+    binary.LittleEndian.PutUint32(b[4:], rate)
+    binary.LittleEndian.PutUint32(b[8:], rate*uint32(channels)*uint32(bits/8))
+    binary.LittleEndian.PutUint16(b[12:], channels*bits/8)
+    binary.LittleEndian.PutUint16(b[14:], bits)
+    return b
+}
+
+// extensibleBody is a WAVE_FORMAT_EXTENSIBLE fmt chunk body whose SubFormat
+// GUID starts with format
+func extensibleBody(format, channels uint16, rate uint32, bits uint16) []byte {
+    b := fmtBody(waveFormatExtensible, channels, rate, bits)
+    ext := make([]byte, 24)
+    binary.LittleEndian.PutUint16(ext[0:], 22)
+    binary.LittleEndian.PutUint16(ext[8:], format)
+    return append(b, ext...)
+}
+
+// wavFile wraps chunks in a RIFF WAVE header
+func wavFile(chunks ...[]byte) []byte {
+    body := []byte("WAVE")
+    for _, chunk := range chunks {
+        body = append(body, chunk...)
+    }
+    return riffChunk("RIFF", body)
+}
+
+func TestReadWAV(t *testing.T) {
+    pcm := []byte{1, 2, 3, 4, 5, 6}
+    streamed := riffChunk("data", nil)
+    binary.LittleEndian.PutUint32(streamed[4:], 0xFFFFFFFF)
+    // Written to a pipe, with neither size filled in
+    unsized := append(wavFile(riffChunk("fmt ", fmtBody(1, 1, 24000, 16)), riffChunk("data", nil)), pcm...)
+    binary.LittleEndian.PutUint32(unsized[4:], 0)
+
+    tests := []struct {
+        name     string
+        wav      []byte
+        format   uint16
+        channels uint16
+        rate     uint32
+        bits     uint16
+        data     []byte
+    }{
+        {"plain", wavFile(riffChunk("fmt ", fmtBody(1, 1, 24000, 16)), riffChunk("data", pcm)), 1, 1, 24000, 16, pcm},
+        {"trailing chunk", wavFile(riffChunk("fmt ", fmtBody(1, 2, 48000, 16)), riffChunk("data", pcm), riffChunk("LIST", []byte("INFO"))), 1, 2, 48000, 16, pcm},
+        {"LIST before fmt", wavFile(riffChunk("LIST", []byte("INFOISFT")), riffChunk("fmt ", fmtBody(1, 1, 16000, 16)), riffChunk("data", pcm)), 1, 1, 16000, 16, pcm},
+        {"odd chunk padded", wavFile(riffChunk("fmt ", fmtBody(1, 1, 8000, 8)), riffChunk("fact", []byte{1, 2, 3}), riffChunk("data", pcm)), 1, 1, 8000, 8, pcm},
+        {"extensible", wavFile(riffChunk("fmt ", extensibleBody(3, 1, 24000, 32)), riffChunk("data", pcm)), 3, 1, 24000, 32, pcm},
+        {"streamed size", wavFile(riffChunk("fmt ", fmtBody(1, 1, 24000, 16)), append(streamed, pcm...)), 1, 1, 24000, 16, pcm},
+        {"empty data", wavFile(riffChunk("fmt ", fmtBody(1, 1, 24000, 16)), riffChunk("data", nil), riffChunk("LIST", []byte("INFOISFT"))), 1, 1, 24000, 16, []byte{}},
+        {"zero size last", append(wavFile(riffChunk("fmt ", fmtBody(1, 1, 24000, 16)), riffChunk("data", nil)), pcm...), 1, 1, 24000, 16, pcm},
+        {"zero sizes", unsized, 1, 1, 24000, 16, pcm},
+    }
+    for _, tt := range tests {
+        header, data, err := readWAV(bytes.NewReader(tt.wav))
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        if header.AudioFormat != tt.format || header.NumChannels != tt.channels ||
+            header.SampleRate != tt.rate || header.BitsPerSample != tt.bits {
+            t.Errorf("%s: got format %d, %d channels, %d Hz, %d bits, want %d, %d, %d, %d", tt.name,
+                header.AudioFormat, header.NumChannels, header.SampleRate, header.BitsPerSample,
+                tt.format, tt.channels, tt.rate, tt.bits)
+        }
+        got, err := io.ReadAll(data)
+        if err != nil {
+            t.Errorf("%s: read data: %v", tt.name, err)
+        } else if !bytes.Equal(got, tt.data) {
+            t.Errorf("%s: data %v, want %v", tt.name, got, tt.data)
+        }
+    }
+}
+
+func TestReadWAVErrors(t *testing.T) {
+    tests := []struct {
+        name string
+        wav  []byte
+        want string
+    }{
+        {"empty", nil, "read RIFF header"},
+        {"not RIFF", append([]byte("RIFX\x00\x00\x00\x00WAVE"), riffChunk("data", nil)...), "invalid WAV format"},
+        {"not WAVE", []byte("RIFF\x00\x00\x00\x00AVI "), "invalid WAV format"},
+        {"no data", wavFile(riffChunk("fmt ", fmtBody(1, 1, 24000, 16))), "no data chunk found"},
+        {"data before fmt", wavFile(riffChunk("data", []byte{0, 0}), riffChunk("fmt ", fmtBody(1, 1, 24000, 16))), "data chunk before fmt chunk"},
+        {"short fmt", wavFile(riffChunk("fmt ", make([]byte, 14)), riffChunk("data", nil)), "fmt chunk too short"},
+        {"short extensible", wavFile(riffChunk("fmt ", fmtBody(waveFormatExtensible, 1, 24000, 16)), riffChunk("data", nil)), "extensible fmt chunk too short"},
+        {"cut off chunk", wavFile(riffChunk("fmt ", fmtBody(1, 1, 24000, 16)), riffChunk("LIST", make([]byte, 8))[:12]), `skip "LIST" chunk`},
+    }
+    for _, tt := range tests {
+        _, _, err := readWAV(bytes.NewReader(tt.wav))
+        if err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("%s: got error %v, want %q", tt.name, err, tt.want)
+        }
+    }
+}
+
+// fakeRealtime is an in-process realtime endpoint. It records every client
+// event and answers it with handle, which by default acknowledges items and
+// streams a short spoken reply to each response.create.
+type fakeRealtime struct {
+    *httptest.Server
+    events     chan fakeEvent
+    handle     func(conn *fakeConn, event fakeEvent)
+    audio      []byte // PCM16 of each reply
+    transcript string // Transcript of each reply
+
+    seq   atomic.Int64
+    mu    sync.Mutex
+    conns []*fakeConn
+}
+
+// fakeConn is one client connection to a fakeRealtime
+type fakeConn struct {
+    n  int // Dial order, from 1
+    ws *websocket.Conn
+    mu sync.Mutex
+}
+
+// fakeEvent is a client event as the fake server received it
+type fakeEvent struct {
+    Type string
+    Body map[string]any
+    Conn int
+}
+
+func newFakeRealtime(t *testing.T) *fakeRealtime {
+    t.Helper()
+    f := &fakeRealtime{
+        events:     make(chan fakeEvent, 1000),
+        audio:      pcmTone(4800),
+        transcript: "Hello there",
+    }
+    f.handle = f.reply
+
+    upgrader := websocket.Upgrader{}
+    f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        ws, err := upgrader.Upgrade(w, r, nil)
+        if err != nil {
+            return
+        }
+        f.mu.Lock()
+        conn := &fakeConn{n: len(f.conns) + 1, ws: ws}
+        f.conns = append(f.conns, conn)
+        f.mu.Unlock()
+        defer ws.Close()
+
+        conn.send(map[string]any{"type": "session.created", "session": map[string]any{"id": fmt.Sprintf("sess_%d", conn.n)}})
+        for {
+            _, data, err := ws.ReadMessage()
+            if err != nil {
+                return
+            }
+            var body map[string]any
+            if err := json.Unmarshal(data, &body); err != nil {
+                continue
+            }
+            event := fakeEvent{Type: fmt.Sprint(body["type"]), Body: body, Conn: conn.n}
+            f.events <- event
+            f.handle(conn, event)
+        }
+    }))
+    t.Cleanup(f.Close)
+    return f
+}
+
+// dial connects to the fake server the way main dials the real one
+func (f *fakeRealtime) dial() (*websocket.Conn, error) {
+    conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(f.URL, "http"), nil)
+    return conn, err
+}
+
+// conn returns the nth connection made to the server, from 1
+func (f *fakeRealtime) conn(n int) *fakeConn {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.conns[n-1]
+}
+
+func (fc *fakeConn) send(event map[string]any) {
+    fc.mu.Lock()
+    defer fc.mu.Unlock()
+    fc.ws.WriteJSON(event)
+}
+
+func (f *fakeRealtime) id(prefix string) string {
+    return fmt.Sprintf("%s_%d", prefix, f.seq.Add(1))
+}
+
+// reply is the default handler
+func (f *fakeRealtime) reply(conn *fakeConn, event fakeEvent) {
+    switch event.Type {
+    case "session.update":
+        conn.send(map[string]any{"type": "session.updated", "session": event.Body["session"]})
+    case "conversation.item.create":
+        item, _ := event.Body["item"].(map[string]any)
+        conn.send(map[string]any{"type": "conversation.item.created", "item": map[string]any{"id": f.id("item"), "role": item["role"]}})
+    case "conversation.item.delete":
+        conn.send(map[string]any{"type": "conversation.item.deleted", "item_id": event.Body["item_id"]})
+    case "response.create":
+        f.stream(conn, event, true)
+    }
+}
+
+// stream sends a spoken reply to a response.create, ending it with
+// response.done if done is set
+func (f *fakeRealtime) stream(conn *fakeConn, event fakeEvent, done bool) (responseID, itemID string) {
+    responseID, itemID = f.id("resp"), f.id("item")
+    var metadata any
+    if options, ok := event.Body["response"].(map[string]any); ok {
+        metadata = options["metadata"]
+    }
+    item := map[string]any{"response_id": responseID, "item_id": itemID, "output_index": 0, "content_index": 0}
+    with := func(fields map[string]any) map[string]any {
+        for k, v := range item {
+            fields[k] = v
+        }
+        return fields
+    }
+
+    conn.send(map[string]any{"type": "response.created", "response": map[string]any{"id": responseID, "metadata": metadata}})
+    conn.send(map[string]any{"type": "conversation.item.created", "item": map[string]any{"id": itemID, "role": "assistant"}})
+    conn.send(with(map[string]any{"type": "response.audio.delta", "delta": base64.StdEncoding.EncodeToString(f.audio)}))
+    conn.send(with(map[string]any{"type": "response.audio_transcript.delta", "delta": f.transcript}))
+    if !done {
+        return responseID, itemID
+    }
+    conn.send(with(map[string]any{"type": "response.audio.done"}))
+    conn.send(with(map[string]any{"type": "response.audio_transcript.done", "transcript": f.transcript}))
+    conn.send(map[string]any{"type": "response.done", "response": map[string]any{
+        "id":       responseID,
+        "status":   "completed",
+        "metadata": metadata,
+        "output": []any{map[string]any{
+            "id":      itemID,
+            "type":    "message",
+            "role":    "assistant",
+            "content": []any{map[string]any{"type": "audio", "transcript": f.transcript}},
+        }},
+    }})
+    return responseID, itemID
+}
+
+// next returns the next client event of one of the given types, skipping
+// others
+func (f *fakeRealtime) next(t *testing.T, types ...string) fakeEvent {
+    t.Helper()
+    timeout := time.After(5 * time.Second)
+    for {
+        select {
+        case event := <-f.events:
+            for _, want := range types {
+                if event.Type == want {
+                    return event
+                }
+            }
+        case <-timeout:
+            t.Fatalf("no %s event received", strings.Join(types, " or "))
+        }
+    }
+}
+
+// pcmTone is n bytes of a PCM16 square wave
+func pcmTone(n int) []byte {
+    pcm := make([]byte, n)
+    for i := 0; i+1 < n; i += 2 {
+        sample := int16(8000)
+        if i/2%48 < 24 {
+            sample = -8000
+        }
+        binary.LittleEndian.PutUint16(pcm[i:], uint16(sample))
+    }
+    return pcm
+}
+
+// syncBuffer collects console output written from several goroutines
+type syncBuffer struct {
+    mu  sync.Mutex
+    buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.buf.String()
+}
+
+// newTestClient connects a client to f and starts its session
+func newTestClient(t *testing.T, f *fakeRealtime, configure func(*audiotypes.ClientConfig)) (*ChatClient, *syncBuffer) {
+    t.Helper()
+    c, out := dialTestClient(t, f, configure)
+    if err := c.startSession(testSession()); err != nil {
+        t.Fatal(err)
+    }
+    f.next(t, "session.update")
+    return c, out
+}
+
+// dialTestClient connects a client to f, with its files in a temporary
+// directory. configure, if given, adjusts the config first. Console output
+// is collected in the returned buffer.
+func dialTestClient(t *testing.T, f *fakeRealtime, configure func(*audiotypes.ClientConfig)) (*ChatClient, *syncBuffer) {
+    t.Helper()
+    config := DefaultConfig()
+    config.AudioOutputDir = t.TempDir()
+    config.LogDir = t.TempDir()
+    config.LiveCaptions = false
+    config.Progress = false
+    if configure != nil {
+        configure(&config)
+    }
+
+    conn, err := f.dial()
+    if err != nil {
+        t.Fatal(err)
+    }
+    c, err := NewChatClient(conn, config)
+    if err != nil {
+        t.Fatal(err)
+    }
+    out := &syncBuffer{}
+    c.Console = console.New(out, io.Discard, "")
+    c.Dial = f.dial
+    t.Cleanup(c.shutdown)
+    return c, out
+}
+
+func testSession() audiotypes.SessionUpdate {
+    return audiotypes.SessionUpdate{
+        Type: "session.update",
+        Session: audiotypes.Session{
+            Modalities:        []string{"text", "audio"},
+            Voice:             "alloy",
+            InputAudioFormat:  "pcm16",
+            OutputAudioFormat: "pcm16",
+        },
+    }
+}
+
+// send runs a line of input as the console loop would
+func send(t *testing.T, c *ChatClient, input string) {
+    t.Helper()
+    msg, err := parseUserInput(input)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := c.sendMessage(msg); err != nil {
+        t.Fatalf("%s: %v", input, err)
+    }
+}
+
+// waitTurn waits for a response to complete
+func waitTurn(t *testing.T, c *ChatClient) string {
+    t.Helper()
+    select {
+    case id := <-c.TurnDone:
+        return id
+    case <-time.After(5 * time.Second):
+        t.Fatal("no response completed")
+        return ""
+    }
+}
+
+func TestSaveCommand(t *testing.T) {
+    f := newFakeRealtime(t)
+    c, _ := newTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.AutoSave = false
+    })
+    dir := c.Config.AudioOutputDir
+
+    // Before any response there is only the session to save
+    send(t, c, "/save empty")
+    if _, err := os.Stat(filepath.Join(dir, "empty", "session.json")); err != nil {
+        t.Error(err)
+    }
+    if _, err := os.Stat(filepath.Join(dir, "empty", "response.wav")); err == nil {
+        t.Error("/save before any response wrote response.wav")
+    }
+
+    send(t, c, "hello")
+    waitTurn(t, c)
+    send(t, c, "/save foo")
+
+    for _, name := range []string{"session.json", "turns.jsonl", "conversation.md"} {
+        if _, err := os.Stat(filepath.Join(dir, "foo", name)); err != nil {
+            t.Error(err)
+        }
+    }
+    wav, err := os.Open(filepath.Join(dir, "foo", "response.wav"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer wav.Close()
+    header, data, err := readWAV(wav)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if header.SampleRate != 24000 || header.BitsPerSample != 16 || header.NumChannels != 1 {
+        t.Errorf("response.wav is %d Hz, %d bits, %d channels", header.SampleRate, header.BitsPerSample, header.NumChannels)
+    }
+    if pcm, _ := io.ReadAll(data); !bytes.Equal(pcm, f.audio) {
+        t.Errorf("response.wav holds %d bytes of audio, want the %d received", len(pcm), len(f.audio))
+    }
+    text, err := os.ReadFile(filepath.Join(dir, "foo", "response.txt"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !strings.Contains(string(text), "Transcript:\n"+f.transcript+"\n") {
+        t.Errorf("response.txt is %q, want the transcript %q", text, f.transcript)
+    }
+
+    // An archive carries the response too, naming its audio as packed
+    send(t, c, "/save foo.zip")
+    archive, err := zip.OpenReader(filepath.Join(dir, "foo.zip"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer archive.Close()
+    packed := make(map[string]string)
+    for _, file := range archive.File {
+        r, err := file.Open()
+        if err != nil {
+            t.Fatal(err)
+        }
+        data, _ := io.ReadAll(r)
+        r.Close()
+        packed[file.Name] = string(data)
+    }
+    if _, data, err := readWAV(strings.NewReader(packed["response.wav"])); err != nil {
+        t.Errorf("packed response.wav: %v", err)
+    } else if pcm, _ := io.ReadAll(data); !bytes.Equal(pcm, f.audio) {
+        t.Errorf("packed response.wav holds %d bytes of audio, want the %d received", len(pcm), len(f.audio))
+    }
+    if !strings.Contains(packed["response.txt"], "Audio File: response.wav\n") {
+        t.Errorf("packed response.txt is %q, want it to name response.wav", packed["response.txt"])
+    }
+}
+
+// eventually waits for cond to hold
+func eventually(t *testing.T, what string, cond func() bool) {
+    t.Helper()
+    for deadline := time.Now().Add(5 * time.Second); !cond(); {
+        if time.Now().After(deadline) {
+            t.Fatalf("timed out waiting for %s", what)
+        }
+        time.Sleep(5 * time.Millisecond)
+    }
+}
+
+func TestParseServerBufferLimit(t *testing.T) {
+    tests := []struct {
+        message string
+        want    time.Duration
+        ok      bool
+    }{
+        {"Input audio buffer exceeds the maximum of 15 seconds", 15 * time.Second, true},
+        {"input_audio_buffer too large: max 1500ms", 1500 * time.Millisecond, true},
+        {"Audio buffer maximum is 2.5 s", 2500 * time.Millisecond, true},
+        {"Buffer exceeds maximum size of 500 milliseconds", 500 * time.Millisecond, true},
+        {"Buffer exceeds maximum of 0 seconds", 0, false},
+        {"Buffer too small, expected at least 100ms of audio", 0, false},
+        {"Invalid value for voice", 0, false},
+    }
+    for _, tt := range tests {
+        event, _ := json.Marshal(audiotypes.ServerErrorEvent{Type: "error", Error: audiotypes.ServerError{Message: tt.message}})
+        got, ok := parseServerBufferLimit(event)
+        if got != tt.want || ok != tt.ok {
+            t.Errorf("%q: got %v, %v, want %v, %v", tt.message, got, ok, tt.want, tt.ok)
+        }
+    }
+}
+
+func TestMaxBufferBytes(t *testing.T) {
+    tests := []struct {
+        name     string
+        seconds  float64
+        serverMs int64
+        format   string
+        want     int64
+    }{
+        {"config only", 2, 0, "pcm16", 2 * 48000},
+        {"no limit", 0, 0, "pcm16", 0},
+        {"server tighter", 2, 500, "pcm16", 24000},
+        {"server looser", 2, 5000, "pcm16", 2 * 48000},
+        {"server without config limit", 0, 1000, "pcm16", 48000},
+        {"g711 rate", 2, 0, audiotypes.AudioFormatG711ULaw, 2 * 8000},
+    }
+    for _, tt := range tests {
+        c := &ChatClient{ChatClient: &audiotypes.ChatClient{
+            Config:         audiotypes.ClientConfig{MaxBufferSeconds: tt.seconds},
+            Session:        audiotypes.Session{InputAudioFormat: tt.format},
+            ServerBufferMs: tt.serverMs,
+        }}
+        if got := c.maxBufferBytes(); got != tt.want {
+            t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+        }
+    }
+}
+
+// commitSizes sends audio as one turn and returns how many bytes of it each
+// input_audio_buffer.commit covered
+func commitSizes(t *testing.T, f *fakeRealtime, c *ChatClient, audio []byte) []int {
+    t.Helper()
+    if err := c.sendAudioData(audio); err != nil {
+        t.Fatal(err)
+    }
+    var sizes []int
+    appended := 0
+    for {
+        event := f.next(t, "input_audio_buffer.append", "input_audio_buffer.commit", "response.create")
+        switch event.Type {
+        case "input_audio_buffer.append":
+            data, err := base64.StdEncoding.DecodeString(fmt.Sprint(event.Body["audio"]))
+            if err != nil {
+                t.Fatal(err)
+            }
+            appended += len(data)
+        case "input_audio_buffer.commit":
+            sizes = append(sizes, appended)
+            appended = 0
+        case "response.create":
+            if appended != 0 {
+                t.Errorf("%d bytes appended after the last commit", appended)
+            }
+            waitTurn(t, c)
+            return sizes
+        }
+    }
+}
+
+func TestServerBufferLimitTightensCommits(t *testing.T) {
+    f := newFakeRealtime(t)
+    c, _ := newTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.MaxBufferSeconds = 300
+    })
+    audio := pcmTone(3 * 48000)
+
+    if sizes := commitSizes(t, f, c, audio); len(sizes) != 1 || sizes[0] != len(audio) {
+        t.Fatalf("without a server limit, commits covered %v bytes, want one of %d", sizes, len(audio))
+    }
+
+    f.conn(1).send(map[string]any{"type": "error", "error": map[string]any{
+        "type":    "invalid_request_error",
+        "message": "Input audio buffer exceeds the maximum of 1 seconds",
+    }})
+    eventually(t, "the server limit", func() bool { return atomic.LoadInt64(&c.ServerBufferMs) == 1000 })
+
+    sizes := commitSizes(t, f, c, audio)
+    total := 0
+    for _, size := range sizes {
+        if size > 48000 {
+            t.Errorf("a commit covered %d bytes, over the server's 1s limit", size)
+        }
+        total += size
+    }
+    if len(sizes) < 3 || total != len(audio) {
+        t.Errorf("with a 1s server limit, commits covered %v bytes, want at least 3 adding up to %d", sizes, len(audio))
+    }
+}
+
+func TestContinueSegmentsG711(t *testing.T) {
+    f := newFakeRealtime(t)
+    c, _ := dialTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.ContinueTurns = true
+        config.MaxBufferSeconds = 1
+        config.SplitOverlapSeconds = 0
+    })
+    session := testSession()
+    session.Session.InputAudioFormat = audiotypes.AudioFormatG711ULaw
+    if err := c.startSession(session); err != nil {
+        t.Fatal(err)
+    }
+    f.next(t, "session.update")
+
+    sent := make(chan error, 1)
+    go func() { sent <- c.sendAudioInput(pcmTone(3 * 48000)) }()
+
+    // 3s of audio against a 1s limit is three turns, whatever the format
+    for i := 0; i < 3; i++ {
+        f.next(t, "response.create")
+    }
+    select {
+    case err := <-sent:
+        if err != nil {
+            t.Fatal(err)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("sending segments did not finish after three turns")
+    }
+    for {
+        select {
+        case event := <-f.events:
+            if event.Type == "response.create" {
+                t.Fatal("more than three turns requested for 3s of audio")
+            }
+        default:
+            return
+        }
+    }
+}
+
+func TestStuckResponseFinalized(t *testing.T) {
+    f := newFakeRealtime(t)
+    f.handle = func(conn *fakeConn, event fakeEvent) {
+        if event.Type == "response.create" {
+            // Deltas, then nothing: no audio.done and no response.done
+            f.stream(conn, event, false)
+            return
+        }
+        f.reply(conn, event)
+    }
+    c, out := newTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.StuckResponseTimeout = 100 * time.Millisecond
+    })
+
+    send(t, c, "hello")
+    var partial []string
+    eventually(t, "the partial response to be saved", func() bool {
+        partial, _ = filepath.Glob(filepath.Join(c.Config.AudioOutputDir, "*_partial.wav"))
+        return len(partial) == 1
+    })
+    eventually(t, "the partial save to be reported", func() bool {
+        return strings.Contains(out.String(), "Response stalled; partial audio saved to "+partial[0])
+    })
+
+    wav, err := os.Open(partial[0])
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer wav.Close()
+    _, data, err := readWAV(wav)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if pcm, _ := io.ReadAll(data); !bytes.Equal(pcm, f.audio) {
+        t.Errorf("partial file holds %d bytes of audio, want the %d received", len(pcm), len(f.audio))
+    }
+
+    // With no transcript.done, the transcript comes from the deltas
+    text, err := os.ReadFile(strings.TrimSuffix(partial[0], ".wav") + ".txt")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !strings.Contains(string(text), f.transcript) {
+        t.Errorf("partial transcript is %q, want the deltas %q", text, f.transcript)
+    }
+}
+
+func TestStuckResponseWatchdogDisabled(t *testing.T) {
+    f := newFakeRealtime(t)
+    f.handle = func(conn *fakeConn, event fakeEvent) {
+        if event.Type == "response.create" {
+            f.stream(conn, event, false)
+            return
+        }
+        f.reply(conn, event)
+    }
+    c, _ := newTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.StuckResponseTimeout = 0
+    })
+
+    send(t, c, "hello")
+    eventually(t, "the audio to be buffered", func() bool {
+        c.AudioMutex.Lock()
+        defer c.AudioMutex.Unlock()
+        return len(c.AudioBuffer) == 1
+    })
+    time.Sleep(200 * time.Millisecond)
+    if partial, _ := filepath.Glob(filepath.Join(c.Config.AudioOutputDir, "*_partial.wav")); len(partial) != 0 {
+        t.Errorf("saved %v with the watchdog disabled", partial)
+    }
+}
+
+func TestResumeReplaysTranscript(t *testing.T) {
+    f := newFakeRealtime(t)
+    c, _ := dialTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.TurnLog = true
+    })
+
+    turns := []audiotypes.TurnRecord{
+        {Role: "user", Kind: "text", Text: "What is the capital of France?"},
+        {Role: "assistant", Kind: "audio", Text: "Paris.", AudioPath: "audio_1.wav"},
+        {Role: "user", Kind: "audio", AudioPath: "question.wav"}, // Nothing to replay
+        {Role: "user", Kind: "transcription", Text: "And of Spain?"},
+        {Role: "tool", Kind: "text", Text: `{"time":"noon"}`},
+        {Role: "assistant", Kind: "audio", Text: "Madrid."},
+    }
+    var saved bytes.Buffer
+    for _, turn := range turns {
+        line, _ := json.Marshal(turn)
+        saved.Write(append(line, '\n'))
+    }
+    if err := os.WriteFile(filepath.Join(c.Config.AudioOutputDir, "session_20240102_030405.jsonl"), saved.Bytes(), 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    path := sessionFile(c.Config.AudioOutputDir, "20240102_030405")
+    if path == "" {
+        t.Fatal("sessionFile did not find the saved session by its timestamp")
+    }
+    if err := c.loadSession(path); err != nil {
+        t.Fatal(err)
+    }
+    if err := c.startSession(testSession()); err != nil {
+        t.Fatal(err)
+    }
+
+    // The new server session gets the settings, then the text turns
+    f.next(t, "session.update")
+    want := []struct{ role, contentType, text string }{
+        {"user", "input_text", "What is the capital of France?"},
+        {"assistant", "text", "Paris."},
+        {"user", "input_text", "And of Spain?"},
+        {"assistant", "text", "Madrid."},
+    }
+    for _, w := range want {
+        event := f.next(t, "conversation.item.create")
+        item, _ := event.Body["item"].(map[string]any)
+        content, _ := item["content"].([]any)
+        if len(content) != 1 {
+            t.Fatalf("replayed item %v, want one content part", item)
+        }
+        part, _ := content[0].(map[string]any)
+        if item["role"] != w.role || part["type"] != w.contentType || part["text"] != w.text {
+            t.Errorf("replayed %v %v %q, want %s %s %q", item["role"], part["type"], part["text"], w.role, w.contentType, w.text)
+        }
+    }
+
+    // Later turns go on the end of the resumed transcript
+    send(t, c, "And of Italy?")
+    waitTurn(t, c)
+    c.shutdown()
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !bytes.HasPrefix(data, saved.Bytes()) || !strings.Contains(string(data[saved.Len():]), `"text":"And of Italy?"`) {
+        t.Errorf("resumed transcript is\n%s\nwant the saved turns followed by the new ones", data)
+    }
+}
+
+func TestSessionFile(t *testing.T) {
+    dir := t.TempDir()
+    saved := filepath.Join(dir, "session_20240102_030405.jsonl")
+    if err := os.WriteFile(saved, nil, 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name string
+        want string
+    }{
+        {"", ""},
+        {"20240102_030405", saved},
+        {saved, saved},
+        {"20240102_999999", ""},
+        {dir, ""}, // A directory is not a transcript
+    }
+    for _, tt := range tests {
+        if got := sessionFile(dir, tt.name); got != tt.want {
+            t.Errorf("sessionFile(%q): got %q, want %q", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestTurnTimeout(t *testing.T) {
+    f := newFakeRealtime(t)
+    var creates atomic.Int64
+    f.handle = func(conn *fakeConn, event fakeEvent) {
+        if event.Type == "response.create" && creates.Add(1) == 1 {
+            return // Never answered
+        }
+        f.reply(conn, event)
+    }
+    c, out := newTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.TurnTimeout = 100 * time.Millisecond
+    })
+
+    send(t, c, "hello")
+    f.next(t, "response.create")
+    f.next(t, "response.cancel")
+    eventually(t, "the user to be told", func() bool {
+        return strings.Contains(out.String(), "No response within 100ms; the turn was cancelled.")
+    })
+    if errors := atomic.LoadInt64(&c.Metrics.Errors); errors != 1 {
+        t.Errorf("recorded %d errors, want 1", errors)
+    }
+
+    // Whoever waited on the turn is released and nothing is left pending
+    waitTurn(t, c)
+    assertNoPendingTurns(t, c)
+
+    send(t, c, "hello again")
+    f.next(t, "response.create")
+    if id := waitTurn(t, c); id == "" {
+        t.Error("the turn after a timeout did not complete")
+    }
+    assertNoPendingTurns(t, c)
+}
+
+func TestRunAskTimesOut(t *testing.T) {
+    f := newFakeRealtime(t)
+    f.handle = func(conn *fakeConn, event fakeEvent) {
+        if event.Type == "response.create" {
+            return // Never answered
+        }
+        f.reply(conn, event)
+    }
+    c, _ := dialTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.TurnTimeout = 100 * time.Millisecond
+    })
+
+    asked := make(chan error, 1)
+    go func() { asked <- c.RunAsk(testSession(), "hello", "", "", "") }()
+    select {
+    case err := <-asked:
+        if err == nil {
+            t.Error("ask succeeded without a response")
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("ask still waiting after the turn timed out")
+    }
+}
+
+func TestTurnTimeoutIgnoresLateDone(t *testing.T) {
+    f := newFakeRealtime(t)
+    stalled := make(chan string, 1)
+    var creates atomic.Int64
+    f.handle = func(conn *fakeConn, event fakeEvent) {
+        if event.Type == "response.create" && creates.Add(1) == 1 {
+            responseID, _ := f.stream(conn, event, false)
+            stalled <- responseID
+            return
+        }
+        f.reply(conn, event)
+    }
+    c, _ := newTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.TurnTimeout = 100 * time.Millisecond
+    })
+
+    send(t, c, "hello")
+    responseID := <-stalled
+    f.next(t, "response.cancel")
+    if id := waitTurn(t, c); id != responseID {
+        t.Errorf("turn done for %q, want the abandoned %q", id, responseID)
+    }
+    assertNoPendingTurns(t, c)
+
+    // The cancelled response ending late does not end another turn
+    f.conn(1).send(map[string]any{"type": "response.done", "response": map[string]any{"id": responseID, "status": "cancelled"}})
+    eventually(t, "the late response.done", func() bool {
+        c.AudioMutex.Lock()
+        defer c.AudioMutex.Unlock()
+        return len(c.Abandoned) == 0 && len(c.Cancelled) == 0
+    })
+    select {
+    case id := <-c.TurnDone:
+        t.Errorf("the late response.done ended turn %q", id)
+    default:
+    }
+
+    send(t, c, "hello again")
+    if id := waitTurn(t, c); id == responseID || id == "" {
+        t.Errorf("turn done for %q, want the new response", id)
+    }
+    assertNoPendingTurns(t, c)
+}
+
+// assertNoPendingTurns checks that c is waiting on no response
+func assertNoPendingTurns(t *testing.T, c *ChatClient) {
+    t.Helper()
+    if pending := atomic.LoadInt64(&c.PendingTurns); pending != 0 {
+        t.Errorf("%d turns still pending", pending)
+    }
+    c.TurnMutex.Lock()
+    defer c.TurnMutex.Unlock()
+    if len(c.Requested) != 0 {
+        t.Errorf("%d requested responses still outstanding", len(c.Requested))
+    }
+}
+
+func TestTurnTimeoutStopsOnResponse(t *testing.T) {
+    f := newFakeRealtime(t)
+    c, out := newTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.TurnTimeout = 100 * time.Millisecond
+    })
+
+    send(t, c, "hello")
+    waitTurn(t, c)
+    time.Sleep(200 * time.Millisecond)
+    for len(f.events) > 0 {
+        if event := <-f.events; event.Type == "response.cancel" {
+            t.Error("a completed turn was cancelled")
+        }
+    }
+    if strings.Contains(out.String(), "No response within") {
+        t.Errorf("console output %q reports a timeout", out.String())
+    }
+}
+
+// audioFixture serves WAV files and failure cases for the audio URL tests
+func audioFixture(t *testing.T, wav []byte) *httptest.Server {
+    t.Helper()
+    mux := http.NewServeMux()
+    mux.HandleFunc("/speech.wav", func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "audio/wav")
+        w.Header().Set("Content-Length", fmt.Sprint(len(wav)))
+        w.Write(wav)
+    })
+    mux.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/octet-stream")
+        w.Write(wav)
+    })
+    mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/html; charset=utf-8")
+        w.Write([]byte("<html></html>"))
+    })
+    mux.HandleFunc("/streamed.wav", func(w http.ResponseWriter, r *http.Request) {
+        // Flushing before the end leaves out Content-Length, so only the
+        // bytes read show the size
+        w.Header().Set("Content-Type", "audio/wav")
+        w.Write(wav[:len(wav)/2])
+        w.(http.Flusher).Flush()
+        w.Write(wav[len(wav)/2:])
+    })
+    mux.HandleFunc("/slow.wav", func(w http.ResponseWriter, r *http.Request) {
+        select {
+        case <-time.After(time.Second):
+        case <-r.Context().Done():
+        }
+    })
+    mux.HandleFunc("/speech.mp3", func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "audio/mpeg")
+        w.Write([]byte("ID3"))
+    })
+    server := httptest.NewServer(mux)
+    t.Cleanup(server.Close)
+    return server
+}
+
+func TestFetchAudioURL(t *testing.T) {
+    pcm := pcmTone(4800)
+    stereo := make([]byte, 0, 2*len(pcm))
+    for i := 0; i < len(pcm); i += 2 {
+        stereo = append(stereo, pcm[i], pcm[i+1], pcm[i], pcm[i+1])
+    }
+    server := audioFixture(t, wavFile(riffChunk("fmt ", fmtBody(1, 1, 24000, 16)), riffChunk("data", pcm)))
+    stereoServer := audioFixture(t, wavFile(riffChunk("fmt ", fmtBody(1, 2, 24000, 16)), riffChunk("data", stereo)))
+
+    tests := []struct {
+        name     string
+        url      string
+        maxBytes int64
+        want     []byte
+        err      string
+    }{
+        {"wav", server.URL + "/speech.wav", 0, pcm, ""},
+        {"octet stream", server.URL + "/download", 0, pcm, ""},
+        {"downmixed", stereoServer.URL + "/speech.wav", 0, pcm, ""},
+        {"within limit", server.URL + "/speech.wav", 1 << 20, pcm, ""},
+        {"not http", "ftp" + strings.TrimPrefix(server.URL, "http") + "/speech.wav", 0, nil, "must be http or https"},
+        {"not found", server.URL + "/missing.wav", 0, nil, "unexpected status 404"},
+        {"not audio", server.URL + "/page.html", 0, nil, "unsupported content type: text/html"},
+        {"declared too large", server.URL + "/speech.wav", 1000, nil, "limit is 1000"},
+        {"streamed too large", server.URL + "/streamed.wav", 1000, nil, "exceeds 1000 byte limit"},
+        {"timeout", server.URL + "/slow.wav", 0, nil, "fetch audio"},
+        {"compressed", server.URL + "/speech.mp3", 0, nil, "transcode audio"},
+    }
+    for _, tt := range tests {
+        c := &ChatClient{ChatClient: &audiotypes.ChatClient{Config: audiotypes.ClientConfig{
+            AudioURLTimeout:  100 * time.Millisecond,
+            MaxAudioURLBytes: tt.maxBytes,
+            FFmpegPath:       filepath.Join(t.TempDir(), "no-ffmpeg"),
+        }}}
+        got, err := c.fetchAudioURL(tt.url)
+        switch {
+        case tt.err != "":
+            if err == nil || !strings.Contains(err.Error(), tt.err) {
+                t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+            }
+        case err != nil:
+            t.Errorf("%s: %v", tt.name, err)
+        case !bytes.Equal(got, tt.want):
+            t.Errorf("%s: got %d bytes of audio, want %d", tt.name, len(got), len(tt.want))
+        }
+    }
+}
+
+func TestAudioURLCommand(t *testing.T) {
+    pcm := pcmTone(4800)
+    server := audioFixture(t, wavFile(riffChunk("fmt ", fmtBody(1, 1, 24000, 16)), riffChunk("data", pcm)))
+    f := newFakeRealtime(t)
+    c, _ := newTestClient(t, f, nil)
+
+    send(t, c, "/audiourl "+server.URL+"/speech.wav")
+    var sent []byte
+    for {
+        event := f.next(t, "input_audio_buffer.append", "input_audio_buffer.commit")
+        if event.Type == "input_audio_buffer.commit" {
+            break
+        }
+        data, _ := base64.StdEncoding.DecodeString(fmt.Sprint(event.Body["audio"]))
+        sent = append(sent, data...)
+    }
+    if !bytes.Equal(sent, pcm) {
+        t.Errorf("sent %d bytes of audio, want the %d fetched", len(sent), len(pcm))
+    }
+    waitTurn(t, c)
+}
+
+func TestDumpConfig(t *testing.T) {
+    config := DefaultConfig()
+    config.APIKey = "sk-not-a-real-key"
+    config.ClientSecret = "ek_not_a_real_secret"
+    config.TurnTimeout = 45 * time.Second
+    session := testSession().Session
+    session.Temperature = 0.7
+    session.Instructions = "Be brief."
+    c := &ChatClient{ChatClient: &audiotypes.ChatClient{Config: config, Session: session}}
+
+    dump := c.DumpConfig()
+    for _, secret := range []string{config.APIKey, config.ClientSecret} {
+        if strings.Contains(dump, secret) {
+            t.Errorf("dump reveals %q", secret)
+        }
+    }
+    if !strings.Contains(dump, `"turn_timeout": "45s"`) {
+        t.Errorf("dump does not write durations as strings:\n%s", dump)
+    }
+
+    var decoded struct {
+        Config  audiotypes.ClientConfig `json:"config"`
+        Session audiotypes.Session      `json:"session"`
+    }
+    if err := json.Unmarshal([]byte(dump), &decoded); err != nil {
+        t.Fatalf("dump does not decode: %v\n%s", err, dump)
+    }
+    want := config
+    want.APIKey, want.ClientSecret = "[redacted]", "[redacted]"
+    if !reflect.DeepEqual(decoded.Config, want) {
+        t.Errorf("config round trip:\ngot  %+v\nwant %+v", decoded.Config, want)
+    }
+    if !reflect.DeepEqual(decoded.Session, session) {
+        t.Errorf("session round trip:\ngot  %+v\nwant %+v", decoded.Session, session)
+    }
+    if c.Config.APIKey != config.APIKey {
+        t.Error("dumping redacted the client's own config")
+    }
+}
+
+// transcriptTurn runs a text turn whose replies carry the given transcripts
+// in turn, the last repeating, and returns the response.create count and
+// the files saved
+func transcriptTurn(t *testing.T, policy string, transcripts ...string) (int, []string) {
+    t.Helper()
+    f := newFakeRealtime(t)
+    var requests atomic.Int64
+    f.handle = func(conn *fakeConn, event fakeEvent) {
+        if event.Type == "response.create" {
+            n := int(requests.Add(1))
+            f.transcript = transcripts[min(n, len(transcripts))-1]
+        }
+        f.reply(conn, event)
+    }
+    c, _ := newTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.EmptyTranscriptPolicy = policy
+        config.Subtitles = false
+    })
+
+    send(t, c, "hello")
+    waitTurn(t, c)
+    c.shutdown()
+
+    var saved []string
+    for _, pattern := range []string{"audio_*.wav", "audio_*.txt"} {
+        matches, _ := filepath.Glob(filepath.Join(c.Config.AudioOutputDir, pattern))
+        saved = append(saved, matches...)
+    }
+    return int(requests.Load()), saved
+}
+
+func TestEmptyTranscriptPlaceholder(t *testing.T) {
+    requests, saved := transcriptTurn(t, audiotypes.EmptyTranscriptPlaceholder, "")
+    if requests != 1 || len(saved) != 2 {
+        t.Fatalf("made %d requests and saved %v, want 1 request and a .wav and .txt", requests, saved)
+    }
+    text, _ := os.ReadFile(saved[1])
+    if !strings.Contains(string(text), "Transcript:\nNo transcript available\n") {
+        t.Errorf("transcript file is %q, want the placeholder", text)
+    }
+}
+
+func TestEmptyTranscriptSkip(t *testing.T) {
+    requests, saved := transcriptTurn(t, audiotypes.EmptyTranscriptSkip, "")
+    if requests != 1 || len(saved) != 1 || !strings.HasSuffix(saved[0], ".wav") {
+        t.Errorf("made %d requests and saved %v, want 1 request and only a .wav", requests, saved)
+    }
+}
+
+func TestEmptyTranscriptRetry(t *testing.T) {
+    requests, saved := transcriptTurn(t, audiotypes.EmptyTranscriptRetry, "", "Hello again")
+    if requests != 2 || len(saved) != 2 {
+        t.Fatalf("made %d requests and saved %v, want 2 requests and one .wav and .txt", requests, saved)
+    }
+    text, _ := os.ReadFile(saved[1])
+    if !strings.Contains(string(text), "Transcript:\nHello again\n") {
+        t.Errorf("transcript file is %q, want the retried response's", text)
+    }
+}
+
+func TestEmptyTranscriptRetryOnce(t *testing.T) {
+    // A second empty transcript is kept with the placeholder
+    requests, saved := transcriptTurn(t, audiotypes.EmptyTranscriptRetry, "")
+    if requests != 2 || len(saved) != 2 {
+        t.Fatalf("made %d requests and saved %v, want 2 requests and one .wav and .txt", requests, saved)
+    }
+    text, _ := os.ReadFile(saved[1])
+    if !strings.Contains(string(text), "Transcript:\nNo transcript available\n") {
+        t.Errorf("transcript file is %q, want the placeholder", text)
+    }
+}
+
+func TestCheckSessionOptions(t *testing.T) {
+    caps := audiotypes.ServerCapabilities{
+        Modalities:   []string{"text"},
+        Voices:       []string{"alloy", "marin"},
+        AudioFormats: []string{"pcm16"},
+    }
+    tests := []struct {
+        name    string
+        session audiotypes.Session
+        err     string
+    }{
+        {"supported", audiotypes.Session{Modalities: []string{"text"}, Voice: "marin", InputAudioFormat: "pcm16", OutputAudioFormat: "pcm16"}, ""},
+        {"defaults left unset", audiotypes.Session{}, ""},
+        {"voice", audiotypes.Session{Voice: "bob"}, `voice "bob" is not supported (supported: alloy, marin)`},
+        {"modality", audiotypes.Session{Modalities: []string{"text", "audio"}}, `modality "audio" is not supported (supported: text)`},
+        {"input format", audiotypes.Session{InputAudioFormat: "g711_ulaw"}, `audio format "g711_ulaw" is not supported (supported: pcm16)`},
+        {"output format", audiotypes.Session{InputAudioFormat: "pcm16", OutputAudioFormat: "g711_alaw"}, `audio format "g711_alaw" is not supported`},
+    }
+    for _, tt := range tests {
+        err := checkSessionOptions(tt.session, caps)
+        switch {
+        case tt.err == "" && err != nil:
+            t.Errorf("%s: %v", tt.name, err)
+        case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+            t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+        }
+    }
+}
+
+func TestObserveSupportedValues(t *testing.T) {
+    defaults := audiotypes.DefaultCapabilities()
+    tests := []struct {
+        name  string
+        error audiotypes.ServerError
+        want  audiotypes.ServerCapabilities
+    }{
+        {
+            "voices",
+            audiotypes.ServerError{Param: "session.voice", Message: "Invalid value: 'bob'. Supported values are: 'alloy', 'ash' and 'marin'."},
+            audiotypes.ServerCapabilities{Modalities: defaults.Modalities, Voices: []string{"alloy", "ash", "marin"}, AudioFormats: defaults.AudioFormats},
+        },
+        {
+            "modalities",
+            audiotypes.ServerError{Param: "session.modalities[1]", Message: "Invalid value: 'video'. Supported values are: 'text' and 'audio'."},
+            audiotypes.ServerCapabilities{Modalities: []string{"text", "audio"}, Voices: defaults.Voices, AudioFormats: defaults.AudioFormats},
+        },
+        {
+            "formats",
+            audiotypes.ServerError{Param: "session.output_audio_format", Message: "Invalid value: 'mp3'. Supported values are: 'pcm16'."},
+            audiotypes.ServerCapabilities{Modalities: defaults.Modalities, Voices: defaults.Voices, AudioFormats: []string{"pcm16"}},
+        },
+        {
+            "other param",
+            audiotypes.ServerError{Param: "session.tool_choice", Message: "Invalid value: 'x'. Supported values are: 'auto', 'none'."},
+            defaults,
+        },
+        {
+            "no list",
+            audiotypes.ServerError{Param: "session.voice", Message: "Cannot update a conversation's voice if assistant audio is present."},
+            defaults,
+        },
+    }
+    for _, tt := range tests {
+        c := &ChatClient{ChatClient: &audiotypes.ChatClient{Capabilities: audiotypes.DefaultCapabilities()}}
+        c.observeSupportedValues(tt.error)
+        if got := c.ServerCapabilities(); !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestCapabilitiesFromServer(t *testing.T) {
+    f := newFakeRealtime(t)
+    f.handle = func(conn *fakeConn, event fakeEvent) {
+        if event.Type == "session.update" {
+            // Echo a voice the built-in list lacks
+            session, _ := event.Body["session"].(map[string]any)
+            session["voice"] = "marin"
+            conn.send(map[string]any{"type": "session.updated", "session": session})
+            return
+        }
+        f.reply(conn, event)
+    }
+    c, _ := newTestClient(t, f, nil)
+
+    eventually(t, "the echoed voice", func() bool {
+        return containsString(c.ServerCapabilities().Voices, "marin")
+    })
+
+    f.conn(1).send(map[string]any{"type": "error", "error": map[string]any{
+        "type":    "invalid_request_error",
+        "param":   "session.voice",
+        "message": "Invalid value: 'bob'. Supported values are: 'alloy' and 'marin'.",
+    }})
+    eventually(t, "the listed voices", func() bool {
+        return reflect.DeepEqual(c.ServerCapabilities().Voices, []string{"alloy", "marin"})
+    })
+
+    session := testSession().Session
+    session.Voice = "ash"
+    if err := checkSessionOptions(session, c.ServerCapabilities()); err == nil {
+        t.Error("a voice the server no longer lists was accepted")
+    }
+    session.Voice = "marin"
+    if err := checkSessionOptions(session, c.ServerCapabilities()); err != nil {
+        t.Error(err)
+    }
+}
+
+func TestReconnectCommand(t *testing.T) {
+    f := newFakeRealtime(t)
+    c, out := newTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.ReplayHistory = true
+    })
+    dials := 0
+    c.Dial = func() (*websocket.Conn, error) {
+        dials++
+        return f.dial()
+    }
+
+    send(t, c, "hello")
+    waitTurn(t, c)
+    send(t, c, "/reconnect")
+    if dials != 1 {
+        t.Fatalf("dialed %d times, want 1", dials)
+    }
+    if !strings.Contains(out.String(), "Reconnected.") {
+        t.Errorf("console output %q does not confirm the reconnect", out.String())
+    }
+
+    // The new connection gets the session, then the conversation so far
+    update := f.next(t, "session.update")
+    if update.Conn != 2 {
+        t.Fatalf("session replayed on connection %d, want 2", update.Conn)
+    }
+    if session, _ := update.Body["session"].(map[string]any); session["voice"] != "alloy" {
+        t.Errorf("replayed session %v, want voice alloy", session)
+    }
+    for _, want := range []struct{ role, text string }{{"user", "hello"}, {"assistant", f.transcript}} {
+        event := f.next(t, "conversation.item.create")
+        item, _ := event.Body["item"].(map[string]any)
+        content, _ := item["content"].([]any)
+        part, _ := content[0].(map[string]any)
+        if event.Conn != 2 || item["role"] != want.role || part["text"] != want.text {
+            t.Errorf("replayed %v %q on connection %d, want %s %q on 2", item["role"], part["text"], event.Conn, want.role, want.text)
+        }
+    }
+
+    send(t, c, "still there?")
+    if event := f.next(t, "response.create"); event.Conn != 2 {
+        t.Errorf("response requested on connection %d, want 2", event.Conn)
+    }
+    waitTurn(t, c)
+    if reconnects := atomic.LoadInt64(&c.Metrics.Reconnects); reconnects != 1 {
+        t.Errorf("counted %d reconnects, want 1", reconnects)
+    }
+}
+
+func TestReconnectCommandMidResponse(t *testing.T) {
+    f := newFakeRealtime(t)
+    var creates atomic.Int64
+    f.handle = func(conn *fakeConn, event fakeEvent) {
+        if event.Type == "response.create" && creates.Add(1) == 1 {
+            f.stream(conn, event, false) // Still streaming when /reconnect runs
+            return
+        }
+        f.reply(conn, event)
+    }
+    c, _ := newTestClient(t, f, nil)
+
+    send(t, c, "hello")
+    f.next(t, "response.create")
+    eventually(t, "the response to start", func() bool {
+        c.AudioMutex.Lock()
+        defer c.AudioMutex.Unlock()
+        return c.Active != nil
+    })
+    send(t, c, "/reconnect")
+    waitTurn(t, c)
+    assertNoTurnInFlight(t, c)
+
+    send(t, c, "still there?")
+    if event := f.next(t, "response.create"); event.Conn != 2 {
+        t.Errorf("response requested on connection %d, want 2", event.Conn)
+    }
+    if id := waitTurn(t, c); id == "" {
+        t.Error("the turn after the reconnect did not complete")
+    }
+    assertNoTurnInFlight(t, c)
+}
+
+func TestReconnectMidResponse(t *testing.T) {
+    f := newFakeRealtime(t)
+    var creates atomic.Int64
+    f.handle = func(conn *fakeConn, event fakeEvent) {
+        if event.Type == "response.create" && creates.Add(1) == 1 {
+            f.stream(conn, event, false)
+            conn.ws.Close() // Dropped without a close frame
+            return
+        }
+        f.reply(conn, event)
+    }
+    c, out := newTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.ReconnectBackoff = 10 * time.Millisecond
+    })
+
+    send(t, c, "hello")
+    waitTurn(t, c)
+    if update := f.next(t, "session.update"); update.Conn != 2 {
+        t.Fatalf("session replayed on connection %d, want 2", update.Conn)
+    }
+    eventually(t, "the reconnect", func() bool { return atomic.LoadInt64(&c.Metrics.Reconnects) == 1 })
+    assertNoTurnInFlight(t, c)
+    if !strings.Contains(out.String(), "The connection dropped before the response finished") {
+        t.Errorf("console output %q does not report the lost response", out.String())
+    }
+
+    send(t, c, "still there?")
+    if event := f.next(t, "response.create"); event.Conn != 2 {
+        t.Errorf("response requested on connection %d, want 2", event.Conn)
+    }
+    if id := waitTurn(t, c); id == "" {
+        t.Error("the turn after the reconnect did not complete")
+    }
+    assertNoTurnInFlight(t, c)
+}
+
+// assertNoTurnInFlight checks that c is waiting on no response and holds
+// no response audio
+func assertNoTurnInFlight(t *testing.T, c *ChatClient) {
+    t.Helper()
+    assertNoPendingTurns(t, c)
+    c.AudioMutex.Lock()
+    defer c.AudioMutex.Unlock()
+    if c.Active != nil || len(c.AudioBuffer) != 0 || len(c.Cancelled) != 0 || len(c.Abandoned) != 0 {
+        t.Errorf("active %v, buffers %d, cancelled %v and abandoned %v left behind",
+            c.Active, len(c.AudioBuffer), c.Cancelled, c.Abandoned)
+    }
+}
+
+func TestReconnectCommandDialFails(t *testing.T) {
+    f := newFakeRealtime(t)
+    c, _ := newTestClient(t, f, nil)
+    c.Dial = func() (*websocket.Conn, error) {
+        return nil, fmt.Errorf("connection refused")
+    }
+
+    msg, _ := parseUserInput("/reconnect")
+    if err := c.sendMessage(msg); err == nil || !strings.Contains(err.Error(), "connection refused") {
+        t.Errorf("got error %v, want the dial failure", err)
+    }
+
+    // The old connection is kept
+    send(t, c, "hello")
+    if event := f.next(t, "response.create"); event.Conn != 1 {
+        t.Errorf("response requested on connection %d, want 1", event.Conn)
+    }
+    waitTurn(t, c)
+}
+
+func TestReconnectWithoutDialer(t *testing.T) {
+    f := newFakeRealtime(t)
+    c, _ := newTestClient(t, f, nil)
+    c.Dial = nil
+
+    msg, _ := parseUserInput("/reconnect")
+    if err := c.sendMessage(msg); err == nil || !strings.Contains(err.Error(), "no dialer configured") {
+        t.Errorf("got error %v, want no dialer", err)
+    }
+}
+
+func TestRetryCommand(t *testing.T) {
+    f := newFakeRealtime(t)
+    c, _ := newTestClient(t, f, nil)
+
+    msg, _ := parseUserInput("/retry")
+    if err := c.sendMessage(msg); err == nil || !strings.Contains(err.Error(), "nothing sent yet") {
+        t.Errorf("retry before any turn: got error %v, want nothing sent", err)
+    }
+
+    send(t, c, "hello")
+    f.next(t, "response.create")
+    waitTurn(t, c)
+    c.AudioMutex.Lock()
+    replyID := c.LastResponse.ItemID
+    c.AudioMutex.Unlock()
+
+    msg, _ = parseUserInput("/retry 2")
+    if err := c.sendMessage(msg); err == nil || !strings.Contains(err.Error(), "temperature must be") {
+        t.Errorf("retry at temperature 2: got error %v, want the range", err)
+    }
+
+    // The reply is deleted and a new one requested; the message is not resent
+    send(t, c, "/retry 0.9")
+    event := f.next(t, "conversation.item.create", "conversation.item.delete")
+    if event.Type != "conversation.item.delete" || event.Body["item_id"] != replyID {
+        t.Errorf("retry sent %s %v, want the delete of reply %s", event.Type, event.Body["item_id"], replyID)
+    }
+    event = f.next(t, "conversation.item.create", "response.create")
+    if event.Type != "response.create" {
+        t.Fatalf("retry sent %s, want response.create", event.Type)
+    }
+    if options, _ := event.Body["response"].(map[string]any); options["temperature"] != 0.9 {
+        t.Errorf("retry requested %v, want temperature 0.9", options)
+    }
+    waitTurn(t, c)
+
+    // The replaced reply is gone from what a reconnect would replay
+    c.HistoryMutex.Lock()
+    transcript := append([]audiotypes.ChatMessage(nil), c.Transcript...)
+    c.HistoryMutex.Unlock()
+    var roles []string
+    for _, entry := range transcript {
+        roles = append(roles, entry.Role)
+    }
+    if strings.Join(roles, " ") != "user assistant" {
+        t.Errorf("transcript after retry has turns %v, want one user and one assistant", roles)
+    }
+}
+
+func TestRetryCancelsReplyInProgress(t *testing.T) {
+    f := newFakeRealtime(t)
+    var creates atomic.Int64
+    var streaming atomic.Value
+    var cancelled, createdAfterDone atomic.Bool
+    f.handle = func(conn *fakeConn, event fakeEvent) {
+        switch {
+        case event.Type == "response.create" && creates.Add(1) == 1:
+            responseID, _ := f.stream(conn, event, false)
+            streaming.Store(responseID)
+        case event.Type == "response.cancel":
+            // The server takes a moment to end the response
+            time.Sleep(100 * time.Millisecond)
+            cancelled.Store(true)
+            conn.send(map[string]any{"type": "response.done", "response": map[string]any{"id": streaming.Load(), "status": "cancelled"}})
+        case event.Type == "response.create":
+            createdAfterDone.Store(cancelled.Load())
+            f.reply(conn, event)
+        default:
+            f.reply(conn, event)
+        }
+    }
+    c, _ := newTestClient(t, f, nil) // Barge-in is off
+
+    send(t, c, "hello")
+    f.next(t, "response.create")
+    eventually(t, "the reply to start", func() bool {
+        c.AudioMutex.Lock()
+        defer c.AudioMutex.Unlock()
+        return c.Active != nil
+    })
+
+    send(t, c, "/retry")
+    f.next(t, "response.cancel")
+    f.next(t, "conversation.item.delete")
+    f.next(t, "response.create")
+    if !createdAfterDone.Load() {
+        t.Error("the new reply was requested before the cancelled one ended")
+    }
+    waitTurn(t, c)
+    assertNoTurnInFlight(t, c)
+}
+
+func TestDaemonSkipsLateReplies(t *testing.T) {
+    f := newFakeRealtime(t)
+    var held *fakeEvent
+    f.handle = func(conn *fakeConn, event fakeEvent) {
+        if event.Type != "response.create" {
+            f.reply(conn, event)
+            return
+        }
+        if held == nil {
+            // The first turn gets no reply until after it times out
+            held = &event
+            return
+        }
+        f.transcript = "late reply to the first turn"
+        f.stream(conn, *held, true)
+        f.transcript = "reply to the second turn"
+        f.stream(conn, event, true)
+    }
+    c, _ := dialTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.TurnTimeout = 200 * time.Millisecond
+    })
+
+    path := filepath.Join(t.TempDir(), "daemon.sock")
+    served := make(chan error, 1)
+    go func() { served <- c.RunDaemon(testSession(), path) }()
+    var conn net.Conn
+    eventually(t, "the daemon socket", func() bool {
+        var err error
+        conn, err = net.Dial("unix", path)
+        return err == nil
+    })
+    defer conn.Close()
+
+    replies := json.NewDecoder(conn)
+    ask := func(req daemonRequest) daemonReply {
+        t.Helper()
+        if err := json.NewEncoder(conn).Encode(req); err != nil {
+            t.Fatal(err)
+        }
+        var reply daemonReply
+        if err := replies.Decode(&reply); err != nil {
+            t.Fatal(err)
+        }
+        return reply
+    }
+
+    first := ask(daemonRequest{ID: json.RawMessage(`1`), Cmd: "text", Text: "first"})
+    if first.OK || first.Error != "timed out waiting for the response" || string(first.ID) != "1" {
+        t.Errorf("first turn: got %+v, want a timeout", first)
+    }
+    second := ask(daemonRequest{ID: json.RawMessage(`"two"`), Cmd: "text", Text: "second"})
+    if !second.OK || second.Transcript != "reply to the second turn" || string(second.ID) != `"two"` {
+        t.Errorf("second turn: got %+v, want its own reply", second)
+    }
+
+    // Each turn's responses carry its tag
+    var tags []any
+    for i := 0; i < 2; i++ {
+        event := f.next(t, "response.create")
+        options, _ := event.Body["response"].(map[string]any)
+        metadata, _ := options["metadata"].(map[string]any)
+        tags = append(tags, metadata[audiotypes.TurnKey])
+    }
+    if fmt.Sprint(tags) != "[1 2]" {
+        t.Errorf("response.create turn tags %v, want [1 2]", tags)
+    }
+
+    if reply := ask(daemonRequest{Cmd: "shutdown"}); !reply.OK {
+        t.Errorf("shutdown: %+v", reply)
+    }
+    select {
+    case err := <-served:
+        if err != nil {
+            t.Error(err)
+        }
+    case <-time.After(5 * time.Second):
+        t.Error("daemon still running after shutdown")
+    }
+}
+
+func TestRateLimitedSendsQueue(t *testing.T) {
+    f := newFakeRealtime(t)
+    c, _ := newTestClient(t, f, nil)
+
+    reset := 300 * time.Millisecond
+    f.conn(1).send(map[string]any{"type": "rate_limits.updated", "rate_limits": []any{
+        map[string]any{"name": "tokens", "limit": 1000, "remaining": 0, "reset_seconds": reset.Seconds()},
+    }})
+    eventually(t, "the rate limit", func() bool {
+        _, ok := c.Metrics.RateLimit("tokens")
+        return ok
+    })
+
+    // The upload is queued rather than waiting out the limit
+    audio := pcmTone(3 * 16 * 1024)
+    start := time.Now()
+    if err := c.sendAudioData(audio); err != nil {
+        t.Fatal(err)
+    }
+    if elapsed := time.Since(start); elapsed >= reset/2 {
+        t.Errorf("sending took %v while the limit was low", elapsed)
+    }
+
+    // The receive loop keeps going meanwhile
+    f.conn(1).send(map[string]any{"type": "rate_limits.updated", "rate_limits": []any{
+        map[string]any{"name": "requests", "limit": 100, "remaining": 99, "reset_seconds": 1},
+    }})
+    eventually(t, "a rate limit update while sends are held back", func() bool {
+        _, ok := c.Metrics.RateLimit("requests")
+        return ok
+    })
+    if time.Since(start) >= reset {
+        t.Error("events were not received while sends were held back")
+    }
+
+    // Once the limit resets the sends go out in order
+    var sent []byte
+    var order []string
+    for len(order) == 0 || order[len(order)-1] != "response.create" {
+        event := f.next(t, "input_audio_buffer.append", "input_audio_buffer.commit", "response.create")
+        if len(order) == 0 && time.Since(start) < reset-50*time.Millisecond {
+            t.Errorf("%s sent %v after the limit ran out, before it reset", event.Type, time.Since(start))
+        }
+        if event.Type == "input_audio_buffer.append" {
+            data, _ := base64.StdEncoding.DecodeString(fmt.Sprint(event.Body["audio"]))
+            sent = append(sent, data...)
+        }
+        if len(order) == 0 || order[len(order)-1] != event.Type {
+            order = append(order, event.Type)
+        }
+    }
+    if want := "input_audio_buffer.append input_audio_buffer.commit response.create"; strings.Join(order, " ") != want {
+        t.Errorf("sent %s, want %s", strings.Join(order, " "), want)
+    }
+    if !bytes.Equal(sent, audio) {
+        t.Errorf("sent %d bytes of audio, want %d", len(sent), len(audio))
+    }
+    waitTurn(t, c)
+}
+
+func TestReconnectReplaysBeyondHistoryRing(t *testing.T) {
+    f := newFakeRealtime(t)
+    c, _ := newTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.BufferSize = 2
+        config.ReplayHistory = true
+    })
+
+    questions := []string{"one", "two", "three"}
+    for _, question := range questions {
+        send(t, c, question)
+        waitTurn(t, c)
+    }
+    if n := len(c.History.Last(0)); n != 2 {
+        t.Errorf("/history ring holds %d turns, want its 2", n)
+    }
+
+    send(t, c, "/reconnect")
+    f.next(t, "session.update")
+    for _, question := range questions {
+        for _, want := range []string{question, f.transcript} {
+            event := f.next(t, "conversation.item.create")
+            item, _ := event.Body["item"].(map[string]any)
+            content, _ := item["content"].([]any)
+            part, _ := content[0].(map[string]any)
+            if event.Conn != 2 || part["text"] != want {
+                t.Errorf("replayed %q on connection %d, want %q on 2", part["text"], event.Conn, want)
+            }
+        }
+    }
+}
+
+func TestPerCallerSessions(t *testing.T) {
+    f := newFakeRealtime(t)
+    dir := t.TempDir()
+    parent, _ := newTestClient(t, f, func(config *audiotypes.ClientConfig) {
+        config.MetricsOut = filepath.Join(dir, "metrics.json")
+        config.SessionSummary = true
+    })
+
+    var sessions []*ChatClient
+    for i := 0; i < 2; i++ {
+        session, err := parent.newSession(nil)
+        if err != nil {
+            t.Fatal(err)
+        }
+        t.Cleanup(session.shutdown)
+        if err := session.startSession(testSession()); err != nil {
+            t.Fatal(err)
+        }
+        sessions = append(sessions, session)
+    }
+
+    // Each session logs under its own ID without touching the default logger
+    a, b := sessions[0], sessions[1]
+    if a.SessionID == b.SessionID || a.log() == b.log() || a.log() == slog.Default() || parent.log() == a.log() {
+        t.Errorf("sessions %q and %q share a logger", a.SessionID, b.SessionID)
+    }
+
+    // Their exit files are named after them, not written over each other
+    for _, session := range sessions {
+        session.shutdown()
+    }
+    parent.shutdown()
+    want := []string{
+        filepath.Join(parent.Config.AudioOutputDir, "session_summary.json"),
+        filepath.Join(parent.Config.AudioOutputDir, "session_summary_"+a.SessionID+".json"),
+        filepath.Join(parent.Config.AudioOutputDir, "session_summary_"+b.SessionID+".json"),
+        filepath.Join(dir, "metrics.json"),
+        filepath.Join(dir, "metrics_"+a.SessionID+".json"),
+        filepath.Join(dir, "metrics_"+b.SessionID+".json"),
+    }
+    for _, path := range want {
+        if _, err := os.Stat(path); err != nil {
+            t.Error(err)
+        }
+    }
+}
+
+func TestFinishedResponsesForgotten(t *testing.T) {
+    f := newFakeRealtime(t)
+    c, _ := newTestClient(t, f, nil)
+
+    for i := 0; i < 3; i++ {
+        send(t, c, "hello")
+        waitTurn(t, c)
+    }
+    c.AudioMutex.Lock()
+    defer c.AudioMutex.Unlock()
+    if len(c.AudioEnded) != 0 || len(c.Positions) != 0 || len(c.AudioBuffer) != 0 || len(c.Watchdogs) != 0 {
+        t.Errorf("after 3 finished turns still tracking %d ended items, %d positions, %d buffers and %d watchdogs",
+            len(c.AudioEnded), len(c.Positions), len(c.AudioBuffer), len(c.Watchdogs))
+    }
+}
+
+func TestAudioOutputTotalDoesNotDrift(t *testing.T) {
+    f := newFakeRealtime(t)
+    f.audio = pcmTone(100) // 50 samples, just over 2ms
+    c, _ := newTestClient(t, f, nil)
+
+    for i := 0; i < 3; i++ {
+        send(t, c, "hello")
+        waitTurn(t, c)
+    }
+    if samples := atomic.LoadInt64(&c.Metrics.AudioOutSamples); samples != 150 {
+        t.Errorf("counted %d samples of output audio, want 150", samples)
+    }
+}
+
+func TestSessionUpdateWhileRead(t *testing.T) {
+    f := newFakeRealtime(t)
+    c, _ := newTestClient(t, f, nil)
+
+    // Run under -race: the session is read elsewhere while a command
+    // changes it
+    stop := make(chan struct{})
+    read := make(chan struct{})
+    go func() {
+        defer close(read)
+        for {
+            select {
+            case <-stop:
+                return
+            default:
+                c.DumpConfig()
+                c.inputSize(4800)
+            }
+        }
+    }()
+    for i := 0; i < 5; i++ {
+        send(t, c, "/temperature 0.7")
+    }
+    close(stop)
+    <-read
+    if temperature := c.session().Temperature; temperature != 0.7 {
+        t.Errorf("session temperature is %v, want 0.7", temperature)
+    }
+}
@@ -2,20 +2,22 @@ package main
 
 import (
 	"bufio"
-	"container/ring"
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"geppetoaudio/audiotypes"
 	"github.com/gorilla/websocket"
 )
 
@@ -25,7 +27,7 @@ type ClientConfig struct {
 	WriteTimeout    time.Duration
 	PingInterval    time.Duration
 	MaxRetries      int
-	BufferSize      int
+	BufferSize      int // Turns kept for /history
 	ShutdownTimeout time.Duration
 }
 
@@ -72,6 +74,7 @@ type ResponseCreate struct {
 type ResponseMessage struct {
 	Type     string   `json:"type"`
 	Response Response `json:"response"`
+	Delta    string   `json:"delta"` // Text of response.text.delta and response.audio_transcript.delta
 }
 
 type Response struct {
@@ -139,8 +142,7 @@ type ChatClient struct {
 	logger         *Logger
 	config         ClientConfig
 	metrics        *Metrics
-	messageBuffer  *ring.Ring
-	bufferMutex    sync.Mutex
+	history        *audiotypes.MessageRing
 }
 
 func NewLogger() (*Logger, error) {
@@ -156,7 +158,7 @@ func NewLogger() (*Logger, error) {
 	}
 
 	timestamp := time.Now().Format("20060102_150405")
-	filename := filepath.Join(logDir, fmt.Sprintf("Chat:%s.log", timestamp))
+	filename := filepath.Join(logDir, fmt.Sprintf("Chat_%s.log", timestamp))
 
 	file, err := os.Create(filename)
 	if err != nil {
@@ -210,7 +212,7 @@ func NewChatClient(conn *websocket.Conn, config ClientConfig) (*ChatClient, erro
 		logger:         logger,
 		config:         config,
 		metrics:        &Metrics{},
-		messageBuffer:  ring.New(config.BufferSize),
+		history:        audiotypes.NewMessageRing(config.BufferSize),
 	}
 
 	// Start ping routine
@@ -235,12 +237,35 @@ func (c *ChatClient) pingRoutine() {
 	}
 }
 
-func (c *ChatClient) bufferMessage(msg string) {
-	c.bufferMutex.Lock()
-	defer c.bufferMutex.Unlock()
+func (c *ChatClient) bufferMessage(role, content string) {
+	c.history.Add(audiotypes.ChatMessage{Role: role, Content: content})
+}
+
+// showHistory prints the last n user and assistant turns
+func (c *ChatClient) showHistory(args string) {
+	n := 10
+	if args != "" {
+		v, err := strconv.Atoi(args)
+		if err != nil || v <= 0 {
+			fmt.Printf("Invalid count %q\n\nYou: ", args)
+			return
+		}
+		n = v
+	}
 
-	c.messageBuffer = c.messageBuffer.Next()
-	c.messageBuffer.Value = msg
+	history := c.history.Last(n)
+	if len(history) == 0 {
+		fmt.Print("No turns yet.\n\nYou: ")
+		return
+	}
+	for _, msg := range history {
+		speaker := "You"
+		if msg.Role == "assistant" {
+			speaker = "Assistant"
+		}
+		fmt.Printf("%s: %s\n", speaker, msg.Content)
+	}
+	fmt.Print("\nYou: ")
 }
 
 func isExitCommand(text string) bool {
@@ -311,6 +336,10 @@ func (c *ChatClient) shutdown() {
 func (c *ChatClient) displayRoutine() {
 	defer c.wg.Done()
 
+	// Deltas are printed as they arrive; the finished message then only
+	// needs to end the line
+	streaming := false
+
 	for {
 		select {
 		case <-c.done:
@@ -319,9 +348,29 @@ func (c *ChatClient) displayRoutine() {
 			if !ok {
 				return
 			}
-			if msg.Role == "assistant" {
-				fmt.Printf("Assistant: %s\n\n", msg.Content)
+			switch msg.Role {
+			case "delta":
+				if !streaming {
+					fmt.Print("Assistant: ")
+					streaming = true
+				}
+				fmt.Print(msg.Content)
+			case "assistant":
+				c.bufferMessage("assistant", msg.Content)
+				if streaming {
+					fmt.Print("\n\n")
+					streaming = false
+				} else {
+					fmt.Printf("Assistant: %s\n\n", msg.Content)
+				}
 				fmt.Print("You: ")
+			case "unfinished":
+				// The response stopped early; msg.Content is its status
+				if streaming {
+					fmt.Printf(" [%s]\n\n", msg.Content)
+					streaming = false
+					fmt.Print("You: ")
+				}
 			}
 		}
 	}
@@ -347,7 +396,12 @@ func (c *ChatClient) inputRoutine() {
 				return
 			}
 
-			c.bufferMessage(text) // Buffer the message
+			if text == "/history" || strings.HasPrefix(text, "/history ") {
+				c.showHistory(strings.TrimSpace(strings.TrimPrefix(text, "/history")))
+				continue
+			}
+
+			c.bufferMessage("user", text) // Buffer the message
 
 			select {
 			case c.messageChannel <- text:
@@ -440,18 +494,31 @@ func (c *ChatClient) receiveRoutine() {
 				continue
 			}
 
-			if resp.Type == "response.done" && resp.Response.Status == "completed" {
+			var display *ChatMessage
+			switch {
+			case resp.Type == "response.text.delta" || resp.Type == "response.audio_transcript.delta":
+				display = &ChatMessage{Role: "delta", Content: resp.Delta}
+			case resp.Type == "response.done" && resp.Response.Status == "completed":
+				var texts []string
 				for _, output := range resp.Response.Output {
 					for _, content := range output.Content {
 						if content.Type == "text" {
-							select {
-							case c.displayChannel <- ChatMessage{Role: "assistant", Content: content.Text}:
-							case <-c.done:
-								return
-							}
+							texts = append(texts, content.Text)
 						}
 					}
 				}
+				if len(texts) > 0 {
+					display = &ChatMessage{Role: "assistant", Content: strings.Join(texts, "\n")}
+				}
+			case resp.Type == "response.done":
+				display = &ChatMessage{Role: "unfinished", Content: resp.Response.Status}
+			}
+			if display != nil {
+				select {
+				case c.displayChannel <- *display:
+				case <-c.done:
+					return
+				}
 			}
 		}
 	}
@@ -537,6 +604,18 @@ func main() {
 		log.Fatal("OPENAI_API_KEY environment variable is not set")
 	}
 
+	baseURL := os.Getenv("OPENAI_REALTIME_URL")
+	if baseURL == "" {
+		baseURL = audiotypes.DefaultBaseURL
+	}
+	flag.StringVar(&baseURL, "url", baseURL, "Realtime WebSocket endpoint (default from OPENAI_REALTIME_URL)")
+	flag.Parse()
+
+	endpoint, err := audiotypes.RealtimeURL(baseURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Get configuration
 	config := DefaultConfig()
 
@@ -553,8 +632,7 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	url := "wss://api.openai.com/v1/realtime?model=gpt-4o-realtime-preview-2024-10-01"
-	conn, _, err := dialer.DialContext(ctx, url, header)
+	conn, _, err := dialer.DialContext(ctx, endpoint.String(), header)
 	if err != nil {
 		log.Fatal("dial:", err)
 	}
@@ -3,12 +3,14 @@ package main
 import (
     "bufio"
     "encoding/json"
+    "flag"
     "fmt"
     "log"
     "os"
     "strings"
     "time"
 
+    "geppetoaudio/audiotypes"
     "github.com/gorilla/websocket"
 )
 
@@ -114,8 +116,19 @@ func main() {
     header["Authorization"] = []string{"Bearer " + apiKey}
     header["OpenAI-Beta"] = []string{"realtime=v1"}
 
-    url := "wss://api.openai.com/v1/realtime?model=gpt-4o-realtime-preview-2024-10-01"
-    c, _, err := websocket.DefaultDialer.Dial(url, header)
+    baseURL := os.Getenv("OPENAI_REALTIME_URL")
+    if baseURL == "" {
+        baseURL = audiotypes.DefaultBaseURL
+    }
+    flag.StringVar(&baseURL, "url", baseURL, "Realtime WebSocket endpoint (default from OPENAI_REALTIME_URL)")
+    flag.Parse()
+
+    endpoint, err := audiotypes.RealtimeURL(baseURL)
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    c, _, err := websocket.DefaultDialer.Dial(endpoint.String(), header)
     if err != nil {
         log.Fatal("dial:", err)
     }
@@ -0,0 +1,453 @@
+// Package mqtt is a small MQTT 3.1.1 client: enough to publish and
+// subscribe at QoS 0 and 1, with a will, over TCP or TLS.
+package mqtt
+
+import (
+    "bufio"
+    "crypto/tls"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/url"
+    "sync"
+    "time"
+)
+
+// Packet types, in the high nibble of the fixed header
+const (
+    packetConnect    = 1
+    packetConnack    = 2
+    packetPublish    = 3
+    packetPuback     = 4
+    packetSubscribe  = 8
+    packetSuback     = 9
+    packetPingreq    = 12
+    packetDisconnect = 14
+)
+
+// ackTimeout bounds the wait for a SUBACK or a QoS 1 PUBACK
+const ackTimeout = 10 * time.Second
+
+// ErrClosed is returned for operations on a closed connection.
+var ErrClosed = errors.New("mqtt: connection closed")
+
+// Message is an application message published to a topic.
+type Message struct {
+    Topic   string
+    Payload []byte
+    QoS     byte // 0 or 1
+    Retain  bool
+}
+
+// Options configure a connection.
+type Options struct {
+    // Broker is the broker's URL: tcp://host:1883 or mqtt://, or
+    // ssl://host:8883, tls:// or mqtts:// for TLS. A user and password in
+    // the URL authenticate the client.
+    Broker    string
+    ClientID  string
+    KeepAlive time.Duration // Zero means 60 seconds
+    // Will, if set, is published by the broker when the connection is
+    // lost without Close.
+    Will *Message
+}
+
+// Client is a connection to a broker. Its methods may be called from any
+// goroutine.
+type Client struct {
+    conn      net.Conn
+    messages  chan Message
+    done      chan struct{}
+    closeOnce sync.Once
+    err       error // Why the connection ended; set before done closes
+
+    writeMutex sync.Mutex
+    mutex      sync.Mutex
+    nextID     uint16
+    acks       map[uint16]chan []byte // Waiting SUBACK and PUBACK payloads
+}
+
+// Dial connects to the broker and completes the MQTT handshake.
+func Dial(opts Options) (*Client, error) {
+    broker, err := url.Parse(opts.Broker)
+    if err != nil {
+        return nil, fmt.Errorf("mqtt: broker: %w", err)
+    }
+    host := broker.Host
+    var conn net.Conn
+    switch broker.Scheme {
+    case "tcp", "mqtt":
+        if broker.Port() == "" {
+            host = net.JoinHostPort(broker.Hostname(), "1883")
+        }
+        conn, err = net.DialTimeout("tcp", host, ackTimeout)
+    case "ssl", "tls", "mqtts":
+        if broker.Port() == "" {
+            host = net.JoinHostPort(broker.Hostname(), "8883")
+        }
+        conn, err = tls.DialWithDialer(&net.Dialer{Timeout: ackTimeout}, "tcp", host, nil)
+    default:
+        return nil, fmt.Errorf("mqtt: unsupported broker scheme %q", broker.Scheme)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("mqtt: %w", err)
+    }
+
+    keepAlive := opts.KeepAlive
+    if keepAlive <= 0 {
+        keepAlive = 60 * time.Second
+    }
+    c := &Client{
+        conn:     conn,
+        messages: make(chan Message, 64),
+        done:     make(chan struct{}),
+        acks:     make(map[uint16]chan []byte),
+    }
+
+    connect := connectPacket(opts, broker.User, keepAlive)
+    conn.SetDeadline(time.Now().Add(ackTimeout))
+    if _, err := conn.Write(connect); err != nil {
+        conn.Close()
+        return nil, fmt.Errorf("mqtt: connect: %w", err)
+    }
+    reader := bufio.NewReader(conn)
+    kind, _, body, err := readPacket(reader)
+    if err == nil && (kind != packetConnack || len(body) < 2) {
+        err = fmt.Errorf("expected CONNACK")
+    }
+    if err == nil && body[1] != 0 {
+        err = connackError(body[1])
+    }
+    if err != nil {
+        conn.Close()
+        return nil, fmt.Errorf("mqtt: connect: %w", err)
+    }
+    conn.SetDeadline(time.Time{})
+
+    go c.readLoop(reader, keepAlive)
+    go c.pingLoop(keepAlive)
+    return c, nil
+}
+
+// Subscribe subscribes to topic filters at qos and waits for the broker
+// to accept them.
+func (c *Client) Subscribe(qos byte, filters ...string) error {
+    id, ack := c.expectAck()
+    var body []byte
+    body = binary.BigEndian.AppendUint16(body, id)
+    for _, filter := range filters {
+        body = appendString(body, filter)
+        body = append(body, qos)
+    }
+    if err := c.write(packetSubscribe<<4|0x02, body); err != nil {
+        return err
+    }
+    codes, err := c.waitAck(id, ack)
+    if err != nil {
+        return fmt.Errorf("mqtt: subscribe: %w", err)
+    }
+    for i, code := range codes {
+        if code == 0x80 && i < len(filters) {
+            return fmt.Errorf("mqtt: subscribe: broker refused %s", filters[i])
+        }
+    }
+    return nil
+}
+
+// Publish sends a message. At QoS 1 it waits for the broker to
+// acknowledge it.
+func (c *Client) Publish(m Message) error {
+    flags := byte(0)
+    if m.Retain {
+        flags |= 0x01
+    }
+    body := appendString(nil, m.Topic)
+    var id uint16
+    var ack chan []byte
+    if m.QoS > 0 {
+        flags |= 1 << 1
+        id, ack = c.expectAck()
+        body = binary.BigEndian.AppendUint16(body, id)
+    }
+    body = append(body, m.Payload...)
+    if err := c.write(packetPublish<<4|flags, body); err != nil {
+        return err
+    }
+    if ack != nil {
+        if _, err := c.waitAck(id, ack); err != nil {
+            return fmt.Errorf("mqtt: publish %s: %w", m.Topic, err)
+        }
+    }
+    return nil
+}
+
+// Messages delivers messages on subscribed topics. It is closed when the
+// connection ends.
+func (c *Client) Messages() <-chan Message {
+    return c.messages
+}
+
+// Done is closed when the connection ends; Err then says why.
+func (c *Client) Done() <-chan struct{} {
+    return c.done
+}
+
+// Err returns why the connection ended, or nil while it is up or after
+// Close.
+func (c *Client) Err() error {
+    select {
+    case <-c.done:
+        return c.err
+    default:
+        return nil
+    }
+}
+
+// Close disconnects cleanly, so the broker does not publish the will.
+func (c *Client) Close() error {
+    err := c.write(packetDisconnect<<4, nil)
+    c.end(nil)
+    return err
+}
+
+// end closes the connection, recording why
+func (c *Client) end(err error) {
+    c.closeOnce.Do(func() {
+        c.err = err
+        close(c.done)
+        c.conn.Close()
+    })
+}
+
+func (c *Client) write(header byte, body []byte) error {
+    select {
+    case <-c.done:
+        return ErrClosed
+    default:
+    }
+    packet := []byte{header}
+    packet = appendLength(packet, len(body))
+    packet = append(packet, body...)
+
+    c.writeMutex.Lock()
+    defer c.writeMutex.Unlock()
+    if _, err := c.conn.Write(packet); err != nil {
+        c.end(err)
+        return fmt.Errorf("mqtt: %w", err)
+    }
+    return nil
+}
+
+// expectAck allocates a packet identifier and registers for its
+// acknowledgement
+func (c *Client) expectAck() (uint16, chan []byte) {
+    c.mutex.Lock()
+    defer c.mutex.Unlock()
+    c.nextID++
+    if c.nextID == 0 {
+        c.nextID = 1
+    }
+    ack := make(chan []byte, 1)
+    c.acks[c.nextID] = ack
+    return c.nextID, ack
+}
+
+func (c *Client) waitAck(id uint16, ack chan []byte) ([]byte, error) {
+    defer func() {
+        c.mutex.Lock()
+        delete(c.acks, id)
+        c.mutex.Unlock()
+    }()
+    select {
+    case payload := <-ack:
+        return payload, nil
+    case <-c.done:
+        return nil, ErrClosed
+    case <-time.After(ackTimeout):
+        return nil, fmt.Errorf("no acknowledgement")
+    }
+}
+
+// readLoop handles packets from the broker until the connection ends
+func (c *Client) readLoop(reader *bufio.Reader, keepAlive time.Duration) {
+    defer close(c.messages)
+    for {
+        // The broker answers every ping, so silence this long is a dead link
+        c.conn.SetReadDeadline(time.Now().Add(keepAlive * 3 / 2))
+        kind, flags, body, err := readPacket(reader)
+        if err != nil {
+            c.end(err)
+            return
+        }
+
+        switch kind {
+        case packetPublish:
+            m, id, err := parsePublish(flags, body)
+            if err != nil {
+                c.end(err)
+                return
+            }
+            if m.QoS > 0 {
+                c.write(packetPuback<<4, binary.BigEndian.AppendUint16(nil, id))
+            }
+            select {
+            case c.messages <- m:
+            case <-c.done:
+                return
+            }
+        case packetPuback, packetSuback:
+            if len(body) < 2 {
+                continue
+            }
+            id := binary.BigEndian.Uint16(body)
+            c.mutex.Lock()
+            ack := c.acks[id]
+            c.mutex.Unlock()
+            if ack != nil {
+                select {
+                case ack <- body[2:]:
+                default: // A duplicate
+                }
+            }
+        }
+    }
+}
+
+// pingLoop keeps an idle connection alive
+func (c *Client) pingLoop(keepAlive time.Duration) {
+    ticker := time.NewTicker(keepAlive / 2)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ticker.C:
+            c.write(packetPingreq<<4, nil)
+        case <-c.done:
+            return
+        }
+    }
+}
+
+func connectPacket(opts Options, user *url.Userinfo, keepAlive time.Duration) []byte {
+    flags := byte(0x02) // Clean session
+    if opts.Will != nil {
+        flags |= 0x04 | opts.Will.QoS<<3
+        if opts.Will.Retain {
+            flags |= 0x20
+        }
+    }
+    password, hasPassword := "", false
+    if user != nil {
+        flags |= 0x80
+        password, hasPassword = user.Password()
+        if hasPassword {
+            flags |= 0x40
+        }
+    }
+
+    body := appendString(nil, "MQTT")
+    body = append(body, 4, flags) // Protocol level 4 is MQTT 3.1.1
+    body = binary.BigEndian.AppendUint16(body, uint16(keepAlive/time.Second))
+    body = appendString(body, opts.ClientID)
+    if opts.Will != nil {
+        body = appendString(body, opts.Will.Topic)
+        body = appendString(body, string(opts.Will.Payload))
+    }
+    if user != nil {
+        body = appendString(body, user.Username())
+        if hasPassword {
+            body = appendString(body, password)
+        }
+    }
+
+    packet := appendLength([]byte{packetConnect << 4}, len(body))
+    return append(packet, body...)
+}
+
+func connackError(code byte) error {
+    switch code {
+    case 1:
+        return fmt.Errorf("broker refused protocol version")
+    case 2:
+        return fmt.Errorf("broker refused client ID")
+    case 3:
+        return fmt.Errorf("broker unavailable")
+    case 4:
+        return fmt.Errorf("bad user name or password")
+    case 5:
+        return fmt.Errorf("not authorized")
+    }
+    return fmt.Errorf("broker refused connection (code %d)", code)
+}
+
+func parsePublish(flags byte, body []byte) (Message, uint16, error) {
+    m := Message{QoS: flags >> 1 & 0x03, Retain: flags&0x01 != 0}
+    if len(body) < 2 {
+        return m, 0, fmt.Errorf("short PUBLISH")
+    }
+    n := int(binary.BigEndian.Uint16(body))
+    if len(body) < 2+n {
+        return m, 0, fmt.Errorf("short PUBLISH")
+    }
+    m.Topic = string(body[2 : 2+n])
+    body = body[2+n:]
+    var id uint16
+    if m.QoS > 0 {
+        if len(body) < 2 {
+            return m, 0, fmt.Errorf("short PUBLISH")
+        }
+        id = binary.BigEndian.Uint16(body)
+        body = body[2:]
+    }
+    m.Payload = body
+    return m, id, nil
+}
+
+// readPacket reads one packet, returning its type, flags and body
+func readPacket(reader *bufio.Reader) (byte, byte, []byte, error) {
+    header, err := reader.ReadByte()
+    if err != nil {
+        return 0, 0, nil, err
+    }
+    // The remaining length is a base-128 varint of up to four bytes
+    length, shift := 0, 0
+    for {
+        b, err := reader.ReadByte()
+        if err != nil {
+            return 0, 0, nil, err
+        }
+        length |= int(b&0x7f) << shift
+        if b&0x80 == 0 {
+            break
+        }
+        shift += 7
+        if shift > 21 {
+            return 0, 0, nil, fmt.Errorf("bad remaining length")
+        }
+    }
+    body := make([]byte, length)
+    if _, err := io.ReadFull(reader, body); err != nil {
+        return 0, 0, nil, err
+    }
+    return header >> 4, header & 0x0f, body, nil
+}
+
+func appendLength(b []byte, n int) []byte {
+    for {
+        digit := byte(n % 128)
+        n /= 128
+        if n > 0 {
+            digit |= 0x80
+        }
+        b = append(b, digit)
+        if n == 0 {
+            return b
+        }
+    }
+}
+
+func appendString(b []byte, s string) []byte {
+    b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+    return append(b, s...)
+}
@@ -0,0 +1,121 @@
+package mqtt
+
+import (
+    "bufio"
+    "bytes"
+    "io"
+    "strings"
+    "testing"
+)
+
+func TestReadPacket(t *testing.T) {
+    long := bytes.Repeat([]byte{'x'}, 200)
+    tests := []struct {
+        name       string
+        data       []byte
+        packetType byte
+        flags      byte
+        body       []byte
+    }{
+        {"ping response", []byte{0xd0, 0x00}, 13, 0, []byte{}},
+        {"PUBACK", []byte{0x40, 0x02, 0x00, 0x07}, packetPuback, 0, []byte{0x00, 0x07}},
+        {"flags", []byte{0x3b, 0x01, 'a'}, packetPublish, 0x0b, []byte{'a'}},
+        {"two-byte length", append([]byte{0x30, 0xc8, 0x01}, long...), packetPublish, 0, long},
+    }
+    for _, tt := range tests {
+        packetType, flags, body, err := readPacket(bufio.NewReader(bytes.NewReader(tt.data)))
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        if packetType != tt.packetType || flags != tt.flags || !bytes.Equal(body, tt.body) {
+            t.Errorf("%s: got type %d flags %#x body %d bytes", tt.name, packetType, flags, len(body))
+        }
+    }
+}
+
+func TestReadPacketErrors(t *testing.T) {
+    tests := []struct {
+        name string
+        data []byte
+    }{
+        {"empty", nil},
+        {"no length", []byte{0x30}},
+        {"length runs on", []byte{0x30, 0x80, 0x80}},
+        {"length over four bytes", []byte{0x30, 0x80, 0x80, 0x80, 0x80, 0x01}},
+        {"short body", []byte{0x30, 0x05, 'a', 'b'}},
+    }
+    for _, tt := range tests {
+        if _, _, _, err := readPacket(bufio.NewReader(bytes.NewReader(tt.data))); err == nil {
+            t.Errorf("%s: read, want an error", tt.name)
+        }
+    }
+}
+
+// The lengths written by appendLength read back, across every width
+func TestRemainingLengthRoundTrip(t *testing.T) {
+    for _, n := range []int{0, 1, 127, 128, 16383, 16384, 2097151, 2097152} {
+        packet := appendLength([]byte{0x30}, n)
+        packet = append(packet, make([]byte, n)...)
+        _, _, body, err := readPacket(bufio.NewReader(bytes.NewReader(packet)))
+        if err != nil || len(body) != n {
+            t.Errorf("length %d: read %d bytes, %v", n, len(body), err)
+        }
+    }
+}
+
+func TestParsePublish(t *testing.T) {
+    topic := appendString(nil, "geppeto/in/text")
+    tests := []struct {
+        name  string
+        flags byte
+        body  []byte
+        want  Message
+        id    uint16
+    }{
+        {"QoS 0", 0x00, append(append([]byte(nil), topic...), "hello"...), Message{Topic: "geppeto/in/text", Payload: []byte("hello")}, 0},
+        {"QoS 1", 0x02, append(append([]byte(nil), topic...), 0x01, 0x02, 'h', 'i'), Message{Topic: "geppeto/in/text", Payload: []byte("hi"), QoS: 1}, 0x0102},
+        {"retained", 0x01, append([]byte(nil), topic...), Message{Topic: "geppeto/in/text", Payload: []byte{}, Retain: true}, 0},
+        {"empty topic", 0x00, []byte{0, 0, 'x'}, Message{Payload: []byte("x")}, 0},
+    }
+    for _, tt := range tests {
+        m, id, err := parsePublish(tt.flags, tt.body)
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        if m.Topic != tt.want.Topic || !bytes.Equal(m.Payload, tt.want.Payload) || m.QoS != tt.want.QoS || m.Retain != tt.want.Retain || id != tt.id {
+            t.Errorf("%s: got %+v id %d, want %+v id %d", tt.name, m, id, tt.want, tt.id)
+        }
+    }
+}
+
+func TestParsePublishErrors(t *testing.T) {
+    tests := []struct {
+        name  string
+        flags byte
+        body  []byte
+    }{
+        {"no topic length", 0x00, []byte{0}},
+        {"topic cut off", 0x00, []byte{0, 5, 'a', 'b'}},
+        {"QoS 1 without an ID", 0x02, append(appendString(nil, "t"), 0x01)},
+    }
+    for _, tt := range tests {
+        if _, _, err := parsePublish(tt.flags, tt.body); err == nil {
+            t.Errorf("%s: parsed, want an error", tt.name)
+        }
+    }
+}
+
+func TestReadPacketStopsAtPacketEnd(t *testing.T) {
+    r := bufio.NewReader(strings.NewReader("\x40\x02\x00\x01\xd0\x00"))
+    if packetType, _, _, err := readPacket(r); err != nil || packetType != packetPuback {
+        t.Fatalf("first packet: type %d, %v", packetType, err)
+    }
+    if packetType, _, _, err := readPacket(r); err != nil || packetType != 13 {
+        t.Fatalf("second packet: type %d, %v", packetType, err)
+    }
+    if _, _, _, err := readPacket(r); err != io.EOF {
+        t.Errorf("after the last packet: %v, want EOF", err)
+    }
+}
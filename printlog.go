@@ -2,14 +2,29 @@ package main
 
 import (
     "bufio"
+    "bytes"
+    "compress/gzip"
+    "encoding/base64"
+    "encoding/binary"
     "encoding/json"
     "flag"
     "fmt"
+    "html"
     "io"
     "log"
+    "math"
     "os"
+    "path"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strconv"
     "strings"
+    "sync"
     "time"
+
+    "geppetoaudio/audiotypes"
+    "github.com/gorilla/websocket"
 )
 
 type LogEntry struct {
@@ -19,8 +34,169 @@ type LogEntry struct {
     RawJSON   interface{}     `json:"raw_json"`
 }
 
+// stringList collects the values of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+    return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+    *l = append(*l, value)
+    return nil
+}
+
+// entryFilter selects which log entries are shown
+type entryFilter struct {
+    types     []string  // Event type globs such as response.audio.*; empty matches all
+    direction string    // "sent" or "received"; empty matches both
+    since     timeBound // Earliest entry shown
+    until     timeBound // Latest entry shown
+    start     time.Time // Timestamp of the first entry, which offsets count from
+}
+
+// timeBound is a -since or -until value: an absolute time, a clock time on
+// the day the log starts, or an offset from its first entry
+type timeBound struct {
+    set    bool
+    at     time.Time
+    clock  bool
+    offset time.Duration
+}
+
+// timeLayouts are the absolute forms accepted by -since and -until
+var timeLayouts = []string{
+    time.RFC3339Nano,
+    "2006-01-02 15:04:05.000",
+    "2006-01-02 15:04:05",
+    "2006-01-02T15:04:05",
+}
+
+func parseTimeBound(value string) (timeBound, error) {
+    if value == "" {
+        return timeBound{}, nil
+    }
+    if d, err := time.ParseDuration(strings.TrimPrefix(value, "+")); err == nil {
+        return timeBound{set: true, offset: d}, nil
+    }
+    for _, layout := range timeLayouts {
+        if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+            return timeBound{set: true, at: t}, nil
+        }
+    }
+    for _, layout := range []string{"15:04:05.000", "15:04:05", "15:04"} {
+        if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+            return timeBound{set: true, at: t, clock: true}, nil
+        }
+    }
+    return timeBound{}, fmt.Errorf("%q is not a time like 2006-01-02 15:04:05 or 15:04:05, nor an offset like 90s", value)
+}
+
+// resolve returns the bound as an absolute time for a log starting at start
+func (b timeBound) resolve(start time.Time) time.Time {
+    switch {
+    case b.clock:
+        start = start.In(time.Local)
+        return time.Date(start.Year(), start.Month(), start.Day(),
+            b.at.Hour(), b.at.Minute(), b.at.Second(), b.at.Nanosecond(), time.Local)
+    case b.at.IsZero():
+        return start.Add(b.offset)
+    }
+    return b.at
+}
+
+func (f *entryFilter) match(entry LogEntry) bool {
+    if f.since.set || f.until.set {
+        t, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+        if err != nil {
+            return false
+        }
+        if f.start.IsZero() {
+            f.start = t
+        }
+        if f.since.set && t.Before(f.since.resolve(f.start)) {
+            return false
+        }
+        if f.until.set && t.After(f.until.resolve(f.start)) {
+            return false
+        }
+    }
+    if f.direction != "" && entry.Direction != f.direction {
+        return false
+    }
+    if len(f.types) == 0 {
+        return true
+    }
+    for _, pattern := range f.types {
+        if ok, _ := path.Match(pattern, entry.Type); ok {
+            return true
+        }
+    }
+    return false
+}
+
+// payloadGrep passes on the entries whose raw JSON matches a pattern, with
+// up to before and after neighbouring entries as context, like grep -B/-A
+type payloadGrep struct {
+    pattern   *regexp.Regexp
+    before    int
+    after     int
+    recent    []LogEntry // Unmatched entries kept for context before the next match
+    remaining int        // Entries still to pass on after the last match
+}
+
+func (g *payloadGrep) add(entry LogEntry, handle func(LogEntry)) {
+    raw, err := json.Marshal(entry.RawJSON)
+    if err == nil && g.pattern.Match(raw) {
+        for _, previous := range g.recent {
+            handle(previous)
+        }
+        g.recent = g.recent[:0]
+        handle(entry)
+        g.remaining = g.after
+        return
+    }
+    if g.remaining > 0 {
+        g.remaining--
+        handle(entry)
+        return
+    }
+    if g.before > 0 {
+        if len(g.recent) == g.before {
+            g.recent = append(g.recent[:0], g.recent[1:]...)
+        }
+        g.recent = append(g.recent, entry)
+    }
+}
+
 type OutputWriter struct {
     writer io.Writer
+    color  bool // Use ANSI colors
+}
+
+// ANSI escape sequences used by colorized output
+const (
+    ansiReset = "\033[0m"
+    ansiBold  = "\033[1m"
+    ansiRed   = "\033[31m"
+    ansiGreen = "\033[32m"
+    ansiBlue  = "\033[34m"
+    ansiCyan  = "\033[36m"
+    ansiGray  = "\033[90m"
+)
+
+// paint wraps s in an ANSI color when the writer is colorized
+func (w *OutputWriter) paint(color, s string) string {
+    if !w.color {
+        return s
+    }
+    return color + s + ansiReset
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+    info, err := f.Stat()
+    return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
 
 func NewOutputWriter(outputFile string) (*OutputWriter, error) {
@@ -64,19 +240,25 @@ func formatTimestamp(timestamp string) string {
 func printLogEntry(writer *OutputWriter, entry LogEntry) {
     // Create direction indicator
     directionArrow := "→"
+    directionColor := ansiBlue
     if entry.Direction == "received" {
         directionArrow = "←"
+        directionColor = ansiGreen
+    }
+    typeColor := ansiBold + ansiCyan
+    if entry.Type == "error" || entry.Direction == "log" && entry.Type == "ERROR" {
+        directionColor = ansiRed
+        typeColor = ansiBold + ansiRed
     }
 
     // Format timestamp
     timestamp := formatTimestamp(entry.Timestamp)
 
     // Print header
-    writer.Write("\n%s %s [%s] %s\n", 
-        timestamp,
-        directionArrow,
-        strings.ToUpper(entry.Direction),
-        strings.ToUpper(entry.Type))
+    writer.Write("\n%s %s %s\n", 
+        writer.paint(ansiGray, timestamp),
+        writer.paint(directionColor, fmt.Sprintf("%s [%s]", directionArrow, strings.ToUpper(entry.Direction))),
+        writer.paint(typeColor, strings.ToUpper(entry.Type)))
 
     // Print separator
     writer.WriteString(strings.Repeat("-", 80) + "\n")
@@ -85,50 +267,1097 @@ func printLogEntry(writer *OutputWriter, entry LogEntry) {
     writer.WriteString(formatJSON(entry.RawJSON) + "\n")
 }
 
-func processLogFile(inputFile string, writer *OutputWriter) error {
-    file, err := os.Open(inputFile)
+// audioExtractor rebuilds audio from a log: response deltas per response
+// item, and appended input audio per committed buffer. Each clip is passed
+// to emit as a WAV file as soon as it is complete.
+type audioExtractor struct {
+    emit          func(name string, wav []byte) error
+    inputFormat   string
+    outputFormat  string
+    responses     map[string]*bytes.Buffer
+    order         []string
+    input         bytes.Buffer
+    inputs        int
+    clips         int
+    unrecoverable int // Payloads logged trimmed or hashed
+}
+
+func newAudioExtractor(emit func(name string, wav []byte) error) *audioExtractor {
+    return &audioExtractor{
+        emit:         emit,
+        inputFormat:  "pcm16",
+        outputFormat: "pcm16",
+        responses:    make(map[string]*bytes.Buffer),
+    }
+}
+
+// writeWAVFiles returns an emit function saving clips as dir/name.wav
+func writeWAVFiles(dir string) func(name string, wav []byte) error {
+    return func(name string, wav []byte) error {
+        return os.WriteFile(filepath.Join(dir, name+".wav"), wav, 0644)
+    }
+}
+
+// rawString returns a string field of an event payload
+func rawString(raw interface{}, key string) string {
+    fields, _ := raw.(map[string]interface{})
+    s, _ := fields[key].(string)
+    return s
+}
+
+// decodePayload decodes a logged base64 audio payload, reporting false for
+// payloads the client logged trimmed or hashed
+func (x *audioExtractor) decodePayload(payload string) ([]byte, bool) {
+    if strings.HasPrefix(payload, "[") {
+        x.unrecoverable++
+        return nil, false
+    }
+    data, err := base64.StdEncoding.DecodeString(payload)
     if err != nil {
-        return fmt.Errorf("error opening input file: %w", err)
+        x.unrecoverable++
+        return nil, false
     }
-    defer file.Close()
+    return data, true
+}
+
+func (x *audioExtractor) add(entry LogEntry) {
+    responseKey := func() string {
+        return fmt.Sprintf("response_%s_%s", rawString(entry.RawJSON, "response_id"), rawString(entry.RawJSON, "item_id"))
+    }
+
+    var err error
+    switch entry.Type {
+    case "session.update", "session.created", "session.updated":
+        fields, _ := entry.RawJSON.(map[string]interface{})
+        session := fields["session"]
+        if format := rawString(session, "input_audio_format"); format != "" {
+            x.inputFormat = format
+        }
+        if format := rawString(session, "output_audio_format"); format != "" {
+            x.outputFormat = format
+        }
+
+    case "response.audio.delta":
+        data, ok := x.decodePayload(rawString(entry.RawJSON, "delta"))
+        if !ok {
+            return
+        }
+        key := responseKey()
+        buf := x.responses[key]
+        if buf == nil {
+            buf = &bytes.Buffer{}
+            x.responses[key] = buf
+            x.order = append(x.order, key)
+        }
+        buf.Write(data)
+
+    case "response.audio.done":
+        err = x.emitResponse(responseKey())
+
+    case "input_audio_buffer.append":
+        if data, ok := x.decodePayload(rawString(entry.RawJSON, "audio")); ok {
+            x.input.Write(data)
+        }
+
+    case "input_audio_buffer.commit":
+        err = x.flushInput()
+    }
+    if err != nil {
+        log.Printf("Error writing audio: %v", err)
+    }
+}
+
+// emitResponse passes on the audio of a finished response item
+func (x *audioExtractor) emitResponse(key string) error {
+    buf := x.responses[key]
+    if buf == nil {
+        return nil
+    }
+    delete(x.responses, key)
+    x.clips++
+    return x.emit(key, wavFile(x.outputFormat, buf.Bytes()))
+}
+
+// flushInput passes on the input audio appended since the last commit
+func (x *audioExtractor) flushInput() error {
+    if x.input.Len() == 0 {
+        return nil
+    }
+    x.inputs++
+    x.clips++
+    err := x.emit(fmt.Sprintf("input_%03d", x.inputs), wavFile(x.inputFormat, x.input.Bytes()))
+    x.input.Reset()
+    return err
+}
+
+// finish passes on responses the log ends in the middle of and any
+// uncommitted input audio
+func (x *audioExtractor) finish() error {
+    if err := x.flushInput(); err != nil {
+        return err
+    }
+    for _, key := range x.order {
+        if err := x.emitResponse(key); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// wavFile wraps audio in an API audio format in a WAV header
+func wavFile(format string, audio []byte) []byte {
+    // pcm16 is 24kHz 16-bit; the G.711 formats are 8kHz 8-bit
+    formatTag, sampleRate, bits := uint16(1), uint32(24000), uint16(16)
+    switch format {
+    case "g711_ulaw":
+        formatTag, sampleRate, bits = 7, 8000, 8
+    case "g711_alaw":
+        formatTag, sampleRate, bits = 6, 8000, 8
+    }
+    blockAlign := bits / 8
+
+    var wav bytes.Buffer
+    wav.WriteString("RIFF")
+    binary.Write(&wav, binary.LittleEndian, uint32(36+len(audio)))
+    wav.WriteString("WAVEfmt ")
+    binary.Write(&wav, binary.LittleEndian, uint32(16))
+    binary.Write(&wav, binary.LittleEndian, formatTag)
+    binary.Write(&wav, binary.LittleEndian, uint16(1))
+    binary.Write(&wav, binary.LittleEndian, sampleRate)
+    binary.Write(&wav, binary.LittleEndian, sampleRate*uint32(blockAlign))
+    binary.Write(&wav, binary.LittleEndian, blockAlign)
+    binary.Write(&wav, binary.LittleEndian, bits)
+    wav.WriteString("data")
+    binary.Write(&wav, binary.LittleEndian, uint32(len(audio)))
+    wav.Write(audio)
+    return wav.Bytes()
+}
+
+// htmlReport renders a log as a standalone HTML page: one collapsible
+// event per entry, colored by direction, with players for the audio
+type htmlReport struct {
+    writer *OutputWriter
+}
+
+const htmlHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+details { margin: 2px 0; border-left: 4px solid #999; padding-left: 8px; }
+details.sent { border-color: #2563eb; }
+details.received { border-color: #16a34a; }
+details.log { border-color: #9ca3af; }
+details.error { border-color: #dc2626; background: #fef2f2; }
+summary { cursor: pointer; font-family: monospace; }
+.time { color: #6b7280; }
+pre { background: #f3f4f6; padding: 8px; overflow-x: auto; }
+.clip { margin: 6px 0 6px 12px; }
+</style>
+</head>
+<body>
+<h1>%s</h1>
+`
+
+func (r *htmlReport) start(title string) {
+    title = html.EscapeString(title)
+    r.writer.Write(htmlHeader, title, title)
+}
+
+func (r *htmlReport) event(entry LogEntry) {
+    class := entry.Direction
+    if entry.Type == "error" || entry.Direction == "log" && entry.Type == "ERROR" {
+        class = "error"
+    }
+    arrow := "→"
+    if entry.Direction == "received" {
+        arrow = "←"
+    }
+    r.writer.Write("<details class=\"%s\"><summary><span class=\"time\">%s</span> %s %s</summary><pre>%s</pre></details>\n",
+        html.EscapeString(class), html.EscapeString(formatTimestamp(entry.Timestamp)), arrow,
+        html.EscapeString(entry.Type), html.EscapeString(formatJSON(entry.RawJSON)))
+}
+
+// audio embeds a clip so the page plays it without separate files
+func (r *htmlReport) audio(name string, wav []byte) error {
+    r.writer.Write("<div class=\"clip\">%s<br><audio controls src=\"data:audio/wav;base64,%s\"></audio></div>\n",
+        html.EscapeString(name), base64.StdEncoding.EncodeToString(wav))
+    return nil
+}
+
+func (r *htmlReport) finish() {
+    r.writer.WriteString("</body>\n</html>\n")
+}
+
+// audioPayloadFields names the field holding base64 audio per event type
+var audioPayloadFields = map[string]string{
+    "response.audio.delta":      "delta",
+    "input_audio_buffer.append": "audio",
+}
+
+// jsonEvent is one entry as written by -json
+type jsonEvent struct {
+    Time       time.Time   `json:"time"`
+    OffsetMs   int64       `json:"offset_ms"` // Since the first entry
+    Direction  string      `json:"direction"`
+    Type       string      `json:"type"`
+    EventID    string      `json:"event_id,omitempty"`
+    ResponseID string      `json:"response_id,omitempty"`
+    ItemID     string      `json:"item_id,omitempty"`
+    AudioBytes int         `json:"audio_bytes,omitempty"` // Decoded size of an audio payload, which is left out
+    Payload    interface{} `json:"payload"`
+}
 
-    scanner := bufio.NewScanner(file)
-    // Increase scanner buffer size for large JSON lines
-    const maxCapacity = 1024 * 1024 // 1MB
-    buf := make([]byte, maxCapacity)
-    scanner.Buffer(buf, maxCapacity)
+// jsonWriter writes entries as normalized JSON lines for jq and friends
+type jsonWriter struct {
+    encoder *json.Encoder
+    start   time.Time
+}
+
+func (j *jsonWriter) add(entry LogEntry) {
+    t, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+    if err != nil {
+        log.Printf("Skipping entry with bad timestamp %q", entry.Timestamp)
+        return
+    }
+    if j.start.IsZero() {
+        j.start = t
+    }
+
+    event := jsonEvent{
+        Time:      t,
+        OffsetMs:  t.Sub(j.start).Milliseconds(),
+        Direction: entry.Direction,
+        Type:      entry.Type,
+        Payload:   entry.RawJSON,
+    }
+    if fields, ok := entry.RawJSON.(map[string]interface{}); ok {
+        event.EventID = rawString(fields, "event_id")
+        event.ResponseID = rawString(fields, "response_id")
+        event.ItemID = rawString(fields, "item_id")
+        if response := rawMap(fields, "response"); event.ResponseID == "" && response != nil {
+            event.ResponseID = rawString(response, "id")
+        }
+        if item := rawMap(fields, "item"); event.ItemID == "" && item != nil {
+            event.ItemID = rawString(item, "id")
+        }
+        if field, ok := audioPayloadFields[entry.Type]; ok {
+            event.AudioBytes = payloadSize(rawString(fields, field))
+            payload := make(map[string]interface{}, len(fields))
+            for key, value := range fields {
+                if key != field {
+                    payload[key] = value
+                }
+            }
+            event.Payload = payload
+        }
+    }
+    if err := j.encoder.Encode(event); err != nil {
+        log.Fatal(err)
+    }
+}
+
+// payloadSize is the decoded size of a logged audio payload, which may be
+// base64 or a trimmed or hashed summary that records its size
+func payloadSize(payload string) int {
+    if m := loggedSize.FindStringSubmatch(payload); m != nil {
+        n, _ := strconv.Atoi(m[1])
+        return n
+    }
+    if strings.HasPrefix(payload, "[") {
+        return 0
+    }
+    return base64.StdEncoding.DecodedLen(len(payload)) - strings.Count(payload, "=")
+}
+
+// loggedSize matches the size in a trimmed or hashed payload summary
+var loggedSize = regexp.MustCompile(`(\d+) bytes\]$`)
+
+// logStats summarizes a whole log for -stats
+type logStats struct {
+    counts     map[string]int // Events by direction and type
+    audioBytes map[string]int // Decoded audio bytes by direction
+    turns      turnPairer
+    usage      map[string]float64 // Summed response.done usage fields
+    errors     map[string]int     // Server error events by code
+    logLevels  map[string]int     // Client diagnostics by level
+    first      time.Time
+    last       time.Time
+}
+
+func newLogStats() *logStats {
+    return &logStats{
+        counts:     make(map[string]int),
+        audioBytes: make(map[string]int),
+        usage:      make(map[string]float64),
+        errors:     make(map[string]int),
+        logLevels:  make(map[string]int),
+    }
+}
 
-    for scanner.Scan() {
-        var entry LogEntry
-        if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
-            log.Printf("Error parsing log entry: %v", err)
+func (s *logStats) add(entry LogEntry) {
+    t, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+    if err == nil {
+        if s.first.IsZero() {
+            s.first = t
+        }
+        s.last = t
+    }
+
+    if entry.Direction == "log" {
+        s.logLevels[entry.Type]++
+        return
+    }
+    s.counts[entry.Direction+" "+entry.Type]++
+    if err == nil {
+        s.turns.add(entry, t)
+    }
+
+    switch entry.Type {
+    case "response.audio.delta":
+        s.audioBytes[entry.Direction] += payloadSize(rawString(entry.RawJSON, "delta"))
+    case "input_audio_buffer.append":
+        s.audioBytes[entry.Direction] += payloadSize(rawString(entry.RawJSON, "audio"))
+    case "response.done":
+        fields, _ := entry.RawJSON.(map[string]interface{})
+        response, _ := fields["response"].(map[string]interface{})
+        addUsage(s.usage, "", response["usage"])
+    case "error":
+        fields, _ := entry.RawJSON.(map[string]interface{})
+        code := rawString(fields["error"], "code")
+        if code == "" {
+            code = rawString(fields["error"], "type")
+        }
+        s.errors[code]++
+    }
+}
+
+// addUsage sums the numeric fields of a usage block, flattening nested
+// details into dotted names such as output_token_details.audio_tokens
+func addUsage(totals map[string]float64, prefix string, usage interface{}) {
+    fields, _ := usage.(map[string]interface{})
+    for key, value := range fields {
+        switch v := value.(type) {
+        case float64:
+            totals[prefix+key] += v
+        case map[string]interface{}:
+            addUsage(totals, prefix+key+".", v)
+        }
+    }
+}
+
+// logTurn is a response.create sent by the client and what came of it
+type logTurn struct {
+    requested  time.Time
+    responseID string
+    firstAudio time.Time // Zero if no audio arrived
+    done       time.Time
+    status     string
+}
+
+// turnPairer matches each sent response.create with its response, by
+// response ID once the server has assigned one and in order before that
+type turnPairer struct {
+    pending  []*logTurn
+    finished []*logTurn
+}
+
+// claim returns the pending turn for a response, assigning the ID to the
+// oldest turn without one if no turn has it yet
+func (p *turnPairer) claim(responseID string) (int, *logTurn) {
+    for i, turn := range p.pending {
+        if turn.responseID == responseID {
+            return i, turn
+        }
+    }
+    for i, turn := range p.pending {
+        if turn.responseID == "" {
+            turn.responseID = responseID
+            return i, turn
+        }
+    }
+    return -1, nil
+}
+
+func (p *turnPairer) add(entry LogEntry, t time.Time) {
+    switch {
+    case entry.Type == "response.create" && entry.Direction == "sent":
+        p.pending = append(p.pending, &logTurn{requested: t})
+    case entry.Type == "response.created":
+        p.claim(rawString(rawMap(entry.RawJSON, "response"), "id"))
+    case entry.Type == "response.audio.delta":
+        if _, turn := p.claim(rawString(entry.RawJSON, "response_id")); turn != nil && turn.firstAudio.IsZero() {
+            turn.firstAudio = t
+        }
+    case entry.Type == "response.done":
+        response := rawMap(entry.RawJSON, "response")
+        i, turn := p.claim(rawString(response, "id"))
+        if turn == nil {
+            // Started by server VAD rather than requested
+            return
+        }
+        turn.done = t
+        turn.status = rawString(response, "status")
+        p.pending = append(p.pending[:i], p.pending[i+1:]...)
+        p.finished = append(p.finished, turn)
+    }
+}
+
+// latencies returns the total and time-to-first-audio latency of every
+// finished turn
+func (p *turnPairer) latencies() (total, firstAudio []time.Duration) {
+    for _, turn := range p.finished {
+        total = append(total, turn.done.Sub(turn.requested))
+        if !turn.firstAudio.IsZero() {
+            firstAudio = append(firstAudio, turn.firstAudio.Sub(turn.requested))
+        }
+    }
+    return total, firstAudio
+}
+
+// distribution formats the percentiles and maximum of durations
+func distribution(durations []time.Duration) string {
+    sorted := append([]time.Duration(nil), durations...)
+    sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+    return fmt.Sprintf("p50 %v, p90 %v, p99 %v, max %v",
+        percentile(sorted, 50).Round(time.Millisecond), percentile(sorted, 90).Round(time.Millisecond),
+        percentile(sorted, 99).Round(time.Millisecond), sorted[len(sorted)-1].Round(time.Millisecond))
+}
+
+// latencyReport prints every turn of a log for -latency
+type latencyReport struct {
+    turns turnPairer
+}
+
+func (r *latencyReport) add(entry LogEntry) {
+    if t, err := time.Parse(time.RFC3339Nano, entry.Timestamp); err == nil {
+        r.turns.add(entry, t)
+    }
+}
+
+func (r *latencyReport) print(writer *OutputWriter) {
+    writer.Write("%-4s %-12s %-34s %12s %12s  %s\n", "#", "requested", "response", "first audio", "done", "status")
+    for i, turn := range r.turns.finished {
+        firstAudio := "-"
+        if !turn.firstAudio.IsZero() {
+            firstAudio = turn.firstAudio.Sub(turn.requested).Round(time.Millisecond).String()
+        }
+        writer.Write("%-4d %-12s %-34s %12s %12s  %s\n", i+1, turn.requested.Format("15:04:05.000"),
+            turn.responseID, firstAudio, turn.done.Sub(turn.requested).Round(time.Millisecond), turn.status)
+    }
+
+    total, firstAudio := r.turns.latencies()
+    writer.Write("\n%d turns", len(total))
+    if len(r.turns.pending) > 0 {
+        writer.Write(", %d without response.done", len(r.turns.pending))
+    }
+    writer.WriteString("\n")
+    if len(total) > 0 {
+        writer.Write("  Total:       %s\n", distribution(total))
+    }
+    if len(firstAudio) > 0 {
+        writer.Write("  First audio: %s\n", distribution(firstAudio))
+    }
+}
+
+
+// replayIdle is how long a replay waits for the server to go quiet after
+// the last event is sent
+const replayIdle = 5 * time.Second
+
+// replayer re-sends the client events of a log for -replay
+type replayer struct {
+    events    []LogEntry
+    times     []time.Time
+    skipped   int // Events whose audio was logged trimmed or hashed
+    mu        sync.Mutex
+    writer    *OutputWriter
+    lastEvent time.Time // Guarded by mu
+}
+
+func (r *replayer) add(entry LogEntry) {
+    if entry.Direction != "sent" {
+        return
+    }
+    t, err := time.Parse(time.RFC3339Nano, entry.Timestamp)
+    if err != nil {
+        return
+    }
+    if field, ok := audioPayloadFields[entry.Type]; ok && strings.HasPrefix(rawString(entry.RawJSON, field), "[") {
+        // The audio itself is gone; sending the summary would only earn an error
+        r.skipped++
+        return
+    }
+    r.events = append(r.events, entry)
+    r.times = append(r.times, t)
+}
+
+// print shows an event sent or received during the replay stamped with
+// the current time, leaving out audio payloads
+func (r *replayer) print(direction, msgType string, raw interface{}) {
+    if field, ok := audioPayloadFields[msgType]; ok {
+        if fields, ok := raw.(map[string]interface{}); ok {
+            if audio, err := base64.StdEncoding.DecodeString(rawString(fields, field)); err == nil {
+                trimmed := make(map[string]interface{}, len(fields))
+                for key, value := range fields {
+                    trimmed[key] = value
+                }
+                trimmed[field] = fmt.Sprintf("[trimmed: %d bytes]", len(audio))
+                raw = trimmed
+            }
+        }
+    }
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.lastEvent = time.Now()
+    printLogEntry(r.writer, LogEntry{
+        Timestamp: r.lastEvent.Format(time.RFC3339Nano),
+        Direction: direction,
+        Type:      msgType,
+        RawJSON:   raw,
+    })
+}
+
+// run connects to endpoint and sends the collected events with their
+// original spacing divided by speed, or back to back when speed is 0,
+// printing what the server sends back until it has been quiet for
+// replayIdle after the last event
+func (r *replayer) run(endpoint string, speed float64) error {
+    if len(r.events) == 0 {
+        return fmt.Errorf("no client events to replay")
+    }
+    target, err := audiotypes.RealtimeURL(endpoint)
+    if err != nil {
+        return fmt.Errorf("-replay: %w", err)
+    }
+
+    key := os.Getenv("OPENAI_CLIENT_SECRET")
+    if key == "" {
+        key = os.Getenv("OPENAI_API_KEY")
+    }
+    header := make(map[string][]string)
+    if key != "" {
+        header["Authorization"] = []string{"Bearer " + key}
+    }
+    header["OpenAI-Beta"] = []string{"realtime=v1"}
+
+    dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second}
+    conn, _, err := dialer.Dial(target.String(), header)
+    if err != nil {
+        return fmt.Errorf("dial %s: %w", target.Redacted(), err)
+    }
+    defer conn.Close()
+
+    received := make(chan error, 1)
+    go func() {
+        for {
+            _, data, err := conn.ReadMessage()
+            if err != nil {
+                received <- err
+                return
+            }
+            var raw map[string]interface{}
+            if err := json.Unmarshal(data, &raw); err != nil {
+                log.Printf("Error parsing server event: %v", err)
+                continue
+            }
+            msgType, _ := raw["type"].(string)
+            r.print("received", msgType, raw)
+        }
+    }()
+
+    start := time.Now()
+    for i, entry := range r.events {
+        if speed > 0 {
+            due := start.Add(time.Duration(float64(r.times[i].Sub(r.times[0])) / speed))
+            time.Sleep(time.Until(due))
+        }
+        data, err := json.Marshal(entry.RawJSON)
+        if err != nil {
+            return fmt.Errorf("encode %s: %w", entry.Type, err)
+        }
+        if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+            return fmt.Errorf("send %s: %w", entry.Type, err)
+        }
+        r.print("sent", entry.Type, entry.RawJSON)
+    }
+
+    for {
+        r.mu.Lock()
+        idle := time.Until(r.lastEvent.Add(replayIdle))
+        r.mu.Unlock()
+        if idle <= 0 {
+            break
+        }
+        select {
+        case err := <-received:
+            if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+                return nil
+            }
+            return fmt.Errorf("connection lost: %w", err)
+        case <-time.After(idle):
+        }
+    }
+    return conn.WriteMessage(websocket.CloseMessage,
+        websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+// percentile picks the nearest-rank p-th percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+    rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+    if rank < 1 {
+        rank = 1
+    }
+    return sorted[rank-1]
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+    keys := make([]string, 0, len(m))
+    for key := range m {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+func (s *logStats) print(writer *OutputWriter) {
+    total := 0
+    for _, n := range s.counts {
+        total += n
+    }
+    writer.Write("Events: %d", total)
+    if !s.first.IsZero() {
+        writer.Write(" from %s to %s (%v)", s.first.Format("2006-01-02 15:04:05"),
+            s.last.Format("15:04:05"), s.last.Sub(s.first).Round(time.Second))
+    }
+    writer.WriteString("\n")
+    for _, key := range sortedKeys(s.counts) {
+        writer.Write("  %-50s %d\n", key, s.counts[key])
+    }
+
+    writer.WriteString("\nAudio:\n")
+    for _, direction := range []string{"sent", "received"} {
+        writer.Write("  %-9s %d bytes\n", direction, s.audioBytes[direction])
+    }
+
+    latencies, _ := s.turns.latencies()
+    writer.Write("\nTurns: %d", len(latencies))
+    if len(latencies) > 0 {
+        writer.Write(" (latency %s)", distribution(latencies))
+    }
+    if len(s.turns.pending) > 0 {
+        writer.Write(", %d unanswered", len(s.turns.pending))
+    }
+    writer.WriteString("\n")
+
+    if len(s.usage) > 0 {
+        writer.WriteString("\nToken usage:\n")
+        for _, key := range sortedKeys(s.usage) {
+            writer.Write("  %-45s %.0f\n", key, s.usage[key])
+        }
+    }
+
+    errors := 0
+    for _, n := range s.errors {
+        errors += n
+    }
+    writer.Write("\nServer errors: %d\n", errors)
+    for _, code := range sortedKeys(s.errors) {
+        writer.Write("  %-30s %d\n", code, s.errors[code])
+    }
+    if len(s.logLevels) > 0 {
+        writer.WriteString("Client diagnostics:\n")
+        for _, level := range sortedKeys(s.logLevels) {
+            writer.Write("  %-30s %d\n", level, s.logLevels[level])
+        }
+    }
+}
+
+// conversationView prints only what was said, for -conversation
+type conversationView struct {
+    writer      *OutputWriter
+    appended    int    // Input audio bytes since the last commit
+    inputFormat string // Needed to turn appended bytes into seconds
+}
+
+// rawList returns a list field of an event payload
+func rawList(raw interface{}, key string) []interface{} {
+    fields, _ := raw.(map[string]interface{})
+    list, _ := fields[key].([]interface{})
+    return list
+}
+
+func (v *conversationView) say(entry LogEntry, speaker, text string) {
+    text = strings.TrimSpace(text)
+    if text == "" {
+        return
+    }
+    clock := entry.Timestamp
+    if t, err := time.Parse(time.RFC3339Nano, entry.Timestamp); err == nil {
+        clock = t.Format("15:04:05")
+    }
+    v.writer.Write("[%s] %s: %s\n", clock, speaker, text)
+}
+
+func (v *conversationView) add(entry LogEntry) {
+    fields, _ := entry.RawJSON.(map[string]interface{})
+    switch {
+    case entry.Type == "session.update" && entry.Direction == "sent":
+        if format := rawString(fields["session"], "input_audio_format"); format != "" {
+            v.inputFormat = format
+        }
+
+    case entry.Type == "conversation.item.create" && entry.Direction == "sent":
+        item := fields["item"]
+        if rawString(item, "role") != "user" {
+            return
+        }
+        for _, content := range rawList(item, "content") {
+            v.say(entry, "You", rawString(content, "text"))
+        }
+
+    case entry.Type == "input_audio_buffer.append":
+        v.appended += payloadSize(rawString(fields, "audio"))
+
+    case entry.Type == "input_audio_buffer.commit":
+        bytesPerSecond := 24000 * 2
+        if strings.HasPrefix(v.inputFormat, "g711") {
+            bytesPerSecond = 8000
+        }
+        if v.appended > 0 {
+            v.say(entry, "You", fmt.Sprintf("[audio, %.1fs]", float64(v.appended)/float64(bytesPerSecond)))
+        }
+        v.appended = 0
+
+    case entry.Type == "conversation.item.input_audio_transcription.completed":
+        v.say(entry, "You (transcribed)", rawString(fields, "transcript"))
+
+    case entry.Type == "response.done":
+        response := fields["response"]
+        speaker := "Assistant"
+        if rawString(rawMap(response, "metadata"), "purpose") == "oob" {
+            speaker = "Assistant (oob)"
+        }
+        for _, output := range rawList(response, "output") {
+            for _, content := range rawList(output, "content") {
+                text := rawString(content, "transcript")
+                if text == "" {
+                    text = rawString(content, "text")
+                }
+                v.say(entry, speaker, text)
+            }
+        }
+    }
+}
+
+// chatMessage is one message of a chat-messages dataset line
+type chatMessage struct {
+    Role    string `json:"role"`
+    Content string `json:"content"`
+}
+
+// chatExport writes each session of a log as one {"messages": [...]} line,
+// the format of OpenAI fine-tuning and evals datasets. Spoken input counts
+// once it has been transcribed; out-of-band responses are left out since
+// they were never part of the conversation.
+type chatExport struct {
+    encoder  *json.Encoder
+    system   string
+    messages []chatMessage
+    spoken   map[string]int // Index in messages of committed audio by item ID
+    sessions int
+}
+
+func newChatExport(w io.Writer) *chatExport {
+    return &chatExport{encoder: json.NewEncoder(w), spoken: make(map[string]int)}
+}
+
+func (c *chatExport) add(entry LogEntry) {
+    fields, _ := entry.RawJSON.(map[string]interface{})
+    switch {
+    case entry.Type == "session.created":
+        c.flush()
+
+    case entry.Type == "session.update" && entry.Direction == "sent":
+        if instructions := rawString(fields["session"], "instructions"); instructions != "" {
+            c.system = instructions
+        }
+
+    case entry.Type == "conversation.item.create" && entry.Direction == "sent":
+        item := fields["item"]
+        if rawString(item, "role") != "user" {
+            return
+        }
+        for _, content := range rawList(item, "content") {
+            c.say("user", rawString(content, "text"))
+        }
+
+    case entry.Type == "input_audio_buffer.committed":
+        // Holds the user's place in the conversation until the transcript,
+        // which may come after the reply, arrives
+        c.spoken[rawString(fields, "item_id")] = len(c.messages)
+        c.messages = append(c.messages, chatMessage{Role: "user"})
+
+    case entry.Type == "conversation.item.input_audio_transcription.completed":
+        if i, ok := c.spoken[rawString(fields, "item_id")]; ok {
+            c.messages[i].Content = strings.TrimSpace(rawString(fields, "transcript"))
+        }
+
+    case entry.Type == "response.done":
+        response := fields["response"]
+        if rawString(rawMap(response, "metadata"), "purpose") == "oob" {
+            return
+        }
+        var text []string
+        for _, output := range rawList(response, "output") {
+            for _, content := range rawList(output, "content") {
+                if s := rawString(content, "transcript"); s != "" {
+                    text = append(text, s)
+                } else if s := rawString(content, "text"); s != "" {
+                    text = append(text, s)
+                }
+            }
+        }
+        c.say("assistant", strings.Join(text, "\n"))
+    }
+}
+
+func (c *chatExport) say(role, text string) {
+    if text = strings.TrimSpace(text); text != "" {
+        c.messages = append(c.messages, chatMessage{Role: role, Content: text})
+    }
+}
+
+// flush writes the session so far, if it has an exchange worth keeping,
+// and starts the next one
+func (c *chatExport) flush() {
+    var messages []chatMessage
+    if c.system != "" {
+        messages = append(messages, chatMessage{Role: "system", Content: c.system})
+    }
+    var user, assistant bool
+    for _, message := range c.messages {
+        if message.Content == "" {
+            // Audio that was never transcribed
             continue
         }
-        printLogEntry(writer, entry)
+        user = user || message.Role == "user"
+        assistant = assistant || message.Role == "assistant"
+        messages = append(messages, message)
+    }
+    c.system, c.messages = "", nil
+    c.spoken = make(map[string]int)
+    if !user || !assistant {
+        return
     }
 
-    if err := scanner.Err(); err != nil {
-        return fmt.Errorf("error reading file: %w", err)
+    line := struct {
+        Messages []chatMessage `json:"messages"`
+    }{messages}
+    if err := c.encoder.Encode(line); err != nil {
+        log.Fatal(err)
     }
+    c.sessions++
+}
+
+// rawMap returns an object field of an event payload
+func rawMap(raw interface{}, key string) map[string]interface{} {
+    fields, _ := raw.(map[string]interface{})
+    m, _ := fields[key].(map[string]interface{})
+    return m
+}
+
+// followInterval is how often -follow checks a live log for new entries
+const followInterval = 250 * time.Millisecond
 
+// processLogFile passes each entry of a log file to handle, or of every
+// log in a directory of rotated logs oldest first. With follow it keeps
+// waiting for entries appended to the newest log by a running client,
+// holding back a partly written line until its newline arrives.
+func processLogFile(inputFile string, follow bool, handle func(LogEntry)) error {
+    info, err := os.Stat(inputFile)
+    if err != nil {
+        return fmt.Errorf("error opening input file: %w", err)
+    }
+    files := []string{inputFile}
+    if info.IsDir() {
+        if files, err = rotatedLogs(inputFile); err != nil {
+            return err
+        }
+    }
+
+    newest := files[len(files)-1]
+    if follow && strings.HasSuffix(newest, ".gz") {
+        return fmt.Errorf("-follow cannot read compressed log %s; run the client without -log-gzip", newest)
+    }
+    for i, name := range files {
+        if err := readLogFile(name, follow && i == len(files)-1, handle); err != nil {
+            return fmt.Errorf("%s: %w", name, err)
+        }
+    }
     return nil
 }
 
+// rotatedLogs lists the .log and .log.gz files in dir in the order they
+// were written. Modification time decides, and the name breaks ties since
+// rotated names carry their timestamp and sequence number.
+func rotatedLogs(dir string) ([]string, error) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return nil, fmt.Errorf("error reading log directory: %w", err)
+    }
+
+    type logFile struct {
+        path    string
+        modTime time.Time
+    }
+    var files []logFile
+    for _, entry := range entries {
+        name := entry.Name()
+        if entry.IsDir() || !(strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".log.gz")) {
+            continue
+        }
+        info, err := entry.Info()
+        if err != nil {
+            return nil, err
+        }
+        files = append(files, logFile{filepath.Join(dir, name), info.ModTime()})
+    }
+    if len(files) == 0 {
+        return nil, fmt.Errorf("no .log or .log.gz files in %s", dir)
+    }
+    sort.Slice(files, func(i, j int) bool {
+        if !files[i].modTime.Equal(files[j].modTime) {
+            return files[i].modTime.Before(files[j].modTime)
+        }
+        return files[i].path < files[j].path
+    })
+
+    paths := make([]string, len(files))
+    for i, file := range files {
+        paths[i] = file.path
+    }
+    return paths, nil
+}
+
+// readLogFile feeds each entry of one log file, gzip-compressed or not, to
+// handle
+func readLogFile(inputFile string, follow bool, handle func(LogEntry)) error {
+    file, err := os.Open(inputFile)
+    if err != nil {
+        return fmt.Errorf("error opening input file: %w", err)
+    }
+    defer file.Close()
+
+    var reader io.Reader = file
+    if strings.HasSuffix(inputFile, ".gz") {
+        gz, err := gzip.NewReader(file)
+        if err != nil {
+            return fmt.Errorf("error opening gzip log: %w", err)
+        }
+        defer gz.Close()
+        reader = gz
+    }
+
+    // Lines are read whole however long they are; audio events can be large
+    lines := bufio.NewReader(reader)
+    var partial []byte
+    for {
+        line, err := lines.ReadBytes('\n')
+        if err == io.EOF && follow {
+            partial = append(partial, line...)
+            time.Sleep(followInterval)
+            continue
+        }
+        if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+            return fmt.Errorf("error reading file: %w", err)
+        }
+        line = append(partial, line...)
+        partial = nil
+
+        if len(strings.TrimSpace(string(line))) > 0 {
+            var entry LogEntry
+            if jsonErr := json.Unmarshal(line, &entry); jsonErr != nil {
+                log.Printf("Error parsing log entry: %v", jsonErr)
+            } else {
+                handle(entry)
+            }
+        }
+
+        if err != nil {
+            // EOF, or a compressed log still being written ending mid-stream
+            return nil
+        }
+    }
+}
+
 func main() {
     // Parse command line flags
-    inputFile := flag.String("f", "", "Input log file to process")
+    inputFile := flag.String("f", "", "Input log file to process (.log or .log.gz), or a directory of rotated logs")
     outputFile := flag.String("o", "", "Output file (optional, defaults to terminal)")
+    var filter entryFilter
+    flag.Var((*stringList)(&filter.types), "type", "Only show events of this type; repeatable, globs like response.audio.* allowed")
+    flag.StringVar(&filter.direction, "direction", "", "Only show sent or received events")
+    since := flag.String("since", "", "Only show events from this time (2006-01-02 15:04:05 or 15:04:05) or offset from the first event (e.g. 5m)")
+    until := flag.String("until", "", "Only show events up to this time or offset from the first event")
+    noColor := flag.Bool("no-color", false, "Never color the output (it is colored only on a terminal, and not when NO_COLOR is set)")
+    follow := flag.Bool("follow", false, "Keep reading as a running client appends to the log, like tail -f")
+    conversation := flag.Bool("conversation", false, "Show only what was said: user messages and assistant transcripts")
+    latency := flag.Bool("latency", false, "Pair each response.create with its first audio delta and response.done and show per-turn latency")
+    replayURL := flag.String("replay", "", "Re-send the client events of the log to this ws:// or wss:// endpoint and print the replies (authenticates with OPENAI_CLIENT_SECRET or OPENAI_API_KEY)")
+    speed := flag.Float64("speed", 1, "Pacing of -replay: 1 keeps the original timing, 2 is twice as fast, 0 sends events back to back")
+    grepPattern := flag.String("grep", "", "Only show events whose raw JSON matches this regular expression, e.g. an item_id")
+    grepBefore := flag.Int("B", 0, "Also show this many events before each -grep match")
+    grepAfter := flag.Int("A", 0, "Also show this many events after each -grep match")
+    exportChat := flag.Bool("export-chat", false, "Write each session as a chat-messages JSON line for OpenAI evals or fine-tuning datasets")
+    stats := flag.Bool("stats", false, "Summarize the log: event counts, audio bytes, turn latencies, token usage and errors")
+    jsonOut := flag.Bool("json", false, "Write one normalized JSON object per event, without audio payloads, for jq and analysis tools")
+    htmlOut := flag.Bool("html", false, "Write a standalone HTML report with collapsible events and audio players")
+    extractDir := flag.String("extract-audio", "", "Write the audio carried by the log as WAV files in this directory instead of printing events")
     flag.Parse()
 
     if *inputFile == "" {
         log.Fatal("Please provide an input log file using the -f flag")
     }
+    var err error
+    var writer *OutputWriter
+    for _, pattern := range filter.types {
+        if _, err := path.Match(pattern, ""); err != nil {
+            log.Fatalf("Invalid -type pattern %q: %v", pattern, err)
+        }
+    }
+    if filter.since, err = parseTimeBound(*since); err != nil {
+        log.Fatalf("Invalid -since: %v", err)
+    }
+    if filter.until, err = parseTimeBound(*until); err != nil {
+        log.Fatalf("Invalid -until: %v", err)
+    }
+    var grep *payloadGrep
+    if *grepPattern != "" {
+        pattern, err := regexp.Compile(*grepPattern)
+        if err != nil {
+            log.Fatalf("Invalid -grep pattern: %v", err)
+        }
+        if *grepBefore < 0 || *grepAfter < 0 {
+            log.Fatal("-A and -B cannot be negative")
+        }
+        grep = &payloadGrep{pattern: pattern, before: *grepBefore, after: *grepAfter}
+    }
+    switch filter.direction {
+    case "", "sent", "received":
+    default:
+        log.Fatalf("Invalid -direction %q: use sent or received", filter.direction)
+    }
 
     // Create output writer
-    writer, err := NewOutputWriter(*outputFile)
+    writer, err = NewOutputWriter(*outputFile)
     if err != nil {
         log.Fatal(err)
     }
+    writer.color = *outputFile == "" && !*noColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
 
     // If we're writing to a file, make sure it's closed properly
     if *outputFile != "" {
@@ -137,8 +1366,98 @@ func main() {
         }
     }
 
+    handle := func(entry LogEntry) { printLogEntry(writer, entry) }
+    var extractor *audioExtractor
+    var summary *logStats
+    var report *htmlReport
+    var latencies *latencyReport
+    var replay *replayer
+    var export *chatExport
+    switch {
+    case *exportChat:
+        export = newChatExport(writer.writer)
+        handle = export.add
+    case *replayURL != "":
+        replay = &replayer{writer: writer}
+        handle = replay.add
+    case *latency:
+        latencies = &latencyReport{}
+        handle = latencies.add
+    case *jsonOut:
+        events := &jsonWriter{encoder: json.NewEncoder(writer.writer)}
+        handle = events.add
+    case *conversation:
+        view := &conversationView{writer: writer, inputFormat: "pcm16"}
+        handle = view.add
+    case *stats:
+        summary = newLogStats()
+        handle = summary.add
+    case *htmlOut:
+        report = &htmlReport{writer: writer}
+        report.start(filepath.Base(*inputFile))
+        extractor = newAudioExtractor(report.audio)
+        handle = func(entry LogEntry) {
+            report.event(entry)
+            extractor.add(entry)
+        }
+    case *extractDir != "":
+        if err := os.MkdirAll(*extractDir, 0755); err != nil {
+            log.Fatal(err)
+        }
+        extractor = newAudioExtractor(writeWAVFiles(*extractDir))
+        handle = extractor.add
+    }
+    if *speed < 0 {
+        log.Fatal("-speed cannot be negative")
+    }
+    if *follow && (summary != nil || extractor != nil || latencies != nil || replay != nil || export != nil) {
+        log.Fatal("-follow only works when printing events")
+    }
+
     // Process the log file
-    if err := processLogFile(*inputFile, writer); err != nil {
+    err = processLogFile(*inputFile, *follow, func(entry LogEntry) {
+        if !filter.match(entry) {
+            return
+        }
+        if grep != nil {
+            grep.add(entry, handle)
+        } else {
+            handle(entry)
+        }
+    })
+    if err != nil {
         log.Fatal(err)
     }
+
+    if extractor != nil {
+        if err := extractor.finish(); err != nil {
+            log.Fatal(err)
+        }
+        if extractor.unrecoverable > 0 {
+            log.Printf("Skipped %d audio payloads that were logged trimmed or hashed", extractor.unrecoverable)
+        }
+    }
+    if report != nil {
+        report.finish()
+    } else if *extractDir != "" {
+        fmt.Printf("Wrote %d WAV files to %s\n", extractor.clips, *extractDir)
+    }
+    if summary != nil {
+        summary.print(writer)
+    }
+    if latencies != nil {
+        latencies.print(writer)
+    }
+    if export != nil {
+        export.flush()
+        log.Printf("Exported %d sessions", export.sessions)
+    }
+    if replay != nil {
+        if replay.skipped > 0 {
+            log.Printf("Skipped %d audio events that were logged trimmed or hashed", replay.skipped)
+        }
+        if err := replay.run(*replayURL, *speed); err != nil {
+            log.Fatal(err)
+        }
+    }
 }
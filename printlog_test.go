@@ -0,0 +1,343 @@
+package main
+
+import (
+    "compress/gzip"
+    "encoding/base64"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestEntryFilterMatch(t *testing.T) {
+    tests := []struct {
+        name   string
+        filter entryFilter
+        entry  LogEntry
+        want   bool
+    }{
+        {"no filter", entryFilter{}, LogEntry{Direction: "sent", Type: "session.update"}, true},
+        {"exact type", entryFilter{types: []string{"error"}}, LogEntry{Type: "error"}, true},
+        {"other type", entryFilter{types: []string{"error"}}, LogEntry{Type: "response.done"}, false},
+        {"glob", entryFilter{types: []string{"response.audio.*"}}, LogEntry{Type: "response.audio.delta"}, true},
+        {"glob spans dots", entryFilter{types: []string{"response.*"}}, LogEntry{Type: "response.audio.delta"}, true},
+        {"glob anchored", entryFilter{types: []string{"audio.*"}}, LogEntry{Type: "response.audio.delta"}, false},
+        {"any of several", entryFilter{types: []string{"error", "response.*"}}, LogEntry{Type: "response.done"}, true},
+        {"direction", entryFilter{direction: "sent"}, LogEntry{Direction: "sent", Type: "response.create"}, true},
+        {"other direction", entryFilter{direction: "sent"}, LogEntry{Direction: "received", Type: "response.done"}, false},
+        {"type and direction", entryFilter{types: []string{"error"}, direction: "sent"}, LogEntry{Direction: "received", Type: "error"}, false},
+    }
+    for _, tt := range tests {
+        if got := tt.filter.match(tt.entry); got != tt.want {
+            t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestPayloadSize(t *testing.T) {
+    tests := []struct {
+        name    string
+        payload string
+        want    int
+    }{
+        {"empty", "", 0},
+        {"base64", base64.StdEncoding.EncodeToString(make([]byte, 4800)), 4800},
+        {"base64 with one pad", base64.StdEncoding.EncodeToString(make([]byte, 5)), 5},
+        {"base64 with two pads", base64.StdEncoding.EncodeToString(make([]byte, 4)), 4},
+        {"trimmed", "[trimmed: 4800 bytes]", 4800},
+        {"hashed", "[sha256: 9f86d081884c7d65, 960 bytes]", 960},
+        {"other summary", "[omitted]", 0},
+    }
+    for _, tt := range tests {
+        if got := payloadSize(tt.payload); got != tt.want {
+            t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestAddUsage(t *testing.T) {
+    totals := map[string]float64{"total_tokens": 10}
+    addUsage(totals, "", map[string]interface{}{
+        "total_tokens": 5.0,
+        "output_token_details": map[string]interface{}{
+            "audio_tokens": 3.0,
+            "text_tokens":  2.0,
+        },
+        "note": "ignored",
+    })
+    want := map[string]float64{
+        "total_tokens":                      15,
+        "output_token_details.audio_tokens": 3,
+        "output_token_details.text_tokens":  2,
+    }
+    if len(totals) != len(want) {
+        t.Fatalf("got %v, want %v", totals, want)
+    }
+    for key, v := range want {
+        if totals[key] != v {
+            t.Errorf("%s: got %v, want %v", key, totals[key], v)
+        }
+    }
+}
+
+func TestLogStatsAdd(t *testing.T) {
+    s := newLogStats()
+    for _, entry := range []LogEntry{
+        {Timestamp: "2024-05-01T10:00:00Z", Direction: "sent", Type: "input_audio_buffer.append",
+            RawJSON: map[string]interface{}{"audio": base64.StdEncoding.EncodeToString(make([]byte, 300))}},
+        {Timestamp: "2024-05-01T10:00:01Z", Direction: "received", Type: "response.audio.delta",
+            RawJSON: map[string]interface{}{"delta": "[trimmed: 1200 bytes]"}},
+        {Timestamp: "2024-05-01T10:00:02Z", Direction: "received", Type: "error",
+            RawJSON: map[string]interface{}{"error": map[string]interface{}{"type": "invalid_request_error"}}},
+        {Timestamp: "2024-05-01T10:00:03Z", Direction: "received", Type: "error",
+            RawJSON: map[string]interface{}{"error": map[string]interface{}{"type": "server_error", "code": "rate_limited"}}},
+        {Timestamp: "2024-05-01T10:00:04Z", Direction: "log", Type: "WARN"},
+    } {
+        s.add(entry)
+    }
+
+    if got := s.audioBytes["sent"]; got != 300 {
+        t.Errorf("sent audio: got %d bytes, want 300", got)
+    }
+    if got := s.audioBytes["received"]; got != 1200 {
+        t.Errorf("received audio: got %d bytes, want 1200", got)
+    }
+    if s.errors["invalid_request_error"] != 1 || s.errors["rate_limited"] != 1 {
+        t.Errorf("errors by code: got %v", s.errors)
+    }
+    if s.counts["received error"] != 2 || s.logLevels["WARN"] != 1 {
+        t.Errorf("counts %v, log levels %v", s.counts, s.logLevels)
+    }
+    if got := s.last.Sub(s.first); got.Seconds() != 4 {
+        t.Errorf("span: got %v, want 4s", got)
+    }
+}
+
+func TestParseTimeBound(t *testing.T) {
+    start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.Local)
+    tests := []struct {
+        value string
+        want  time.Time // Resolved against start
+    }{
+        {"90s", start.Add(90 * time.Second)},
+        {"+2m", start.Add(2 * time.Minute)},
+        {"2024-05-01 10:30:00", time.Date(2024, 5, 1, 10, 30, 0, 0, time.Local)},
+        {"2024-05-01 10:30:00.250", time.Date(2024, 5, 1, 10, 30, 0, 250e6, time.Local)},
+        {"2024-05-01T10:30:00", time.Date(2024, 5, 1, 10, 30, 0, 0, time.Local)},
+        {"2024-05-02T08:00:00Z", time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC)},
+        {"11:15", time.Date(2024, 5, 1, 11, 15, 0, 0, time.Local)},
+        {"11:15:30", time.Date(2024, 5, 1, 11, 15, 30, 0, time.Local)},
+        {"11:15:30.500", time.Date(2024, 5, 1, 11, 15, 30, 500e6, time.Local)},
+    }
+    for _, tt := range tests {
+        bound, err := parseTimeBound(tt.value)
+        if err != nil {
+            t.Errorf("%s: %v", tt.value, err)
+            continue
+        }
+        if got := bound.resolve(start); !bound.set || !got.Equal(tt.want) {
+            t.Errorf("%s: resolved to %v, want %v", tt.value, got, tt.want)
+        }
+    }
+
+    if bound, err := parseTimeBound(""); err != nil || bound.set {
+        t.Errorf("empty value: got %+v, %v, want an unset bound", bound, err)
+    }
+    for _, value := range []string{"yesterday", "10:61", "2024-13-01 00:00:00"} {
+        if _, err := parseTimeBound(value); err == nil {
+            t.Errorf("%s: parsed, want an error", value)
+        }
+    }
+}
+
+func TestEntryFilterTimeRange(t *testing.T) {
+    since, _ := parseTimeBound("1s")
+    until, _ := parseTimeBound("3s")
+    f := entryFilter{since: since, until: until}
+
+    var kept []string
+    for _, ts := range []string{
+        "2024-05-01T10:00:00Z", // The first entry sets the start
+        "2024-05-01T10:00:01Z",
+        "2024-05-01T10:00:02.5Z",
+        "2024-05-01T10:00:03Z",
+        "2024-05-01T10:00:03.001Z",
+        "not a time",
+    } {
+        if f.match(LogEntry{Timestamp: ts}) {
+            kept = append(kept, ts)
+        }
+    }
+    want := []string{"2024-05-01T10:00:01Z", "2024-05-01T10:00:02.5Z", "2024-05-01T10:00:03Z"}
+    if len(kept) != len(want) {
+        t.Fatalf("kept %v, want %v", kept, want)
+    }
+    for i := range want {
+        if kept[i] != want[i] {
+            t.Errorf("kept %v, want %v", kept, want)
+            break
+        }
+    }
+}
+
+func TestTurnPairer(t *testing.T) {
+    base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+    at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+    response := func(id, status string) map[string]interface{} {
+        return map[string]interface{}{"response": map[string]interface{}{"id": id, "status": status}}
+    }
+
+    var p turnPairer
+    for _, e := range []struct {
+        ms    int
+        entry LogEntry
+    }{
+        {0, LogEntry{Direction: "sent", Type: "response.create"}},
+        {100, LogEntry{Direction: "sent", Type: "response.create"}},
+        {200, LogEntry{Direction: "received", Type: "response.created", RawJSON: response("resp_a", "")}},
+        {300, LogEntry{Direction: "received", Type: "response.created", RawJSON: response("resp_b", "")}},
+        {400, LogEntry{Direction: "received", Type: "response.audio.delta", RawJSON: map[string]interface{}{"response_id": "resp_b"}}},
+        {500, LogEntry{Direction: "received", Type: "response.audio.delta", RawJSON: map[string]interface{}{"response_id": "resp_b"}}},
+        {600, LogEntry{Direction: "received", Type: "response.done", RawJSON: response("resp_b", "completed")}},
+        // Started by server VAD, so nothing was requested
+        {650, LogEntry{Direction: "received", Type: "response.done", RawJSON: response("resp_vad", "completed")}},
+        {900, LogEntry{Direction: "received", Type: "response.done", RawJSON: response("resp_a", "cancelled")}},
+    } {
+        p.add(e.entry, at(e.ms))
+    }
+
+    if len(p.pending) != 0 || len(p.finished) != 2 {
+        t.Fatalf("%d pending, %d finished, want 0 and 2", len(p.pending), len(p.finished))
+    }
+    b, a := p.finished[0], p.finished[1]
+    if b.responseID != "resp_b" || b.status != "completed" || a.responseID != "resp_a" || a.status != "cancelled" {
+        t.Errorf("finished %+v then %+v", *b, *a)
+    }
+
+    total, firstAudio := p.latencies()
+    wantTotal := []time.Duration{500 * time.Millisecond, 900 * time.Millisecond}
+    if len(total) != 2 || total[0] != wantTotal[0] || total[1] != wantTotal[1] {
+        t.Errorf("total latencies %v, want %v", total, wantTotal)
+    }
+    if len(firstAudio) != 1 || firstAudio[0] != 300*time.Millisecond {
+        t.Errorf("first audio latencies %v, want [300ms]", firstAudio)
+    }
+}
+
+func TestDistribution(t *testing.T) {
+    var durations []time.Duration
+    for i := 100; i >= 1; i-- {
+        durations = append(durations, time.Duration(i)*time.Millisecond)
+    }
+    got := distribution(durations)
+    if want := "p50 50ms, p90 90ms, p99 99ms, max 100ms"; got != want {
+        t.Errorf("got %q, want %q", got, want)
+    }
+    if durations[0] != 100*time.Millisecond {
+        t.Error("distribution sorted its argument in place")
+    }
+}
+
+// writeLog writes lines to name in dir, gzip-compressed for a .gz name, and
+// dates it mtime
+func writeLog(t *testing.T, dir, name string, mtime time.Time, lines ...string) string {
+    t.Helper()
+    path := filepath.Join(dir, name)
+    file, err := os.Create(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    text := strings.Join(lines, "\n") + "\n"
+    if strings.HasSuffix(name, ".gz") {
+        gz := gzip.NewWriter(file)
+        gz.Write([]byte(text))
+        err = gz.Close()
+    } else {
+        _, err = file.WriteString(text)
+    }
+    if err != nil {
+        t.Fatal(err)
+    }
+    file.Close()
+    if err := os.Chtimes(path, mtime, mtime); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+func entryLine(msgType string) string {
+    return `{"timestamp":"2024-05-01T10:00:00Z","direction":"received","type":"` + msgType + `","raw_json":{}}`
+}
+
+func TestProcessLogFileDirectory(t *testing.T) {
+    dir := t.TempDir()
+    now := time.Now()
+    writeLog(t, dir, "client_2.log.gz", now.Add(-time.Hour), entryLine("second"))
+    writeLog(t, dir, "client_1.log.gz", now.Add(-time.Hour), entryLine("first"))
+    writeLog(t, dir, "client.log", now, entryLine("third"), "", "not json", entryLine("fourth"))
+    writeLog(t, dir, "notes.txt", now, entryLine("ignored"))
+
+    var types []string
+    if err := processLogFile(dir, false, func(e LogEntry) { types = append(types, e.Type) }); err != nil {
+        t.Fatal(err)
+    }
+    // Same-age logs go by name, and the bad line is skipped
+    if got, want := strings.Join(types, " "), "first second third fourth"; got != want {
+        t.Errorf("read %q, want %q", got, want)
+    }
+}
+
+func TestProcessLogFileSingle(t *testing.T) {
+    dir := t.TempDir()
+    path := writeLog(t, dir, "one.log.gz", time.Now(), entryLine("only"))
+    var types []string
+    if err := processLogFile(path, false, func(e LogEntry) { types = append(types, e.Type) }); err != nil {
+        t.Fatal(err)
+    }
+    if len(types) != 1 || types[0] != "only" {
+        t.Errorf("read %v, want [only]", types)
+    }
+}
+
+func TestProcessLogFileErrors(t *testing.T) {
+    dir := t.TempDir()
+    if err := processLogFile(dir, false, func(LogEntry) {}); err == nil {
+        t.Error("empty directory read, want an error")
+    }
+    path := writeLog(t, dir, "old.log.gz", time.Now(), entryLine("x"))
+    if err := processLogFile(path, true, func(LogEntry) {}); err == nil {
+        t.Error("followed a compressed log, want an error")
+    }
+    if err := processLogFile(filepath.Join(dir, "missing.log"), false, func(LogEntry) {}); err == nil {
+        t.Error("missing file read, want an error")
+    }
+}
+
+func TestPayloadGrep(t *testing.T) {
+    // Entries carry their name in the payload; those named with an x match
+    names := "a b x1 c d e x2 f x3 g h"
+    tests := []struct {
+        before, after int
+        want          string
+    }{
+        {0, 0, "x1 x2 x3"},
+        {1, 0, "b x1 e x2 f x3"},
+        {0, 1, "x1 c x2 f x3 g"},
+        {2, 2, "a b x1 c d e x2 f x3 g h"},
+        {5, 0, "a b x1 c d e x2 f x3"},
+    }
+    for _, tt := range tests {
+        g := payloadGrep{pattern: regexp.MustCompile(`"x\d"`), before: tt.before, after: tt.after}
+        var got []string
+        for _, name := range strings.Fields(names) {
+            g.add(LogEntry{RawJSON: map[string]interface{}{"name": name}}, func(e LogEntry) {
+                got = append(got, rawString(e.RawJSON, "name"))
+            })
+        }
+        if strings.Join(got, " ") != tt.want {
+            t.Errorf("-B %d -A %d: got %q, want %q", tt.before, tt.after, strings.Join(got, " "), tt.want)
+        }
+    }
+}
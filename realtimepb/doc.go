@@ -0,0 +1,5 @@
+// Package realtimepb holds the gRPC Realtime service that mainaudio -grpc
+// serves, generated from realtime.proto.
+package realtimepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative realtime.proto
@@ -0,0 +1,184 @@
+package sip
+
+import (
+    "bytes"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// message is a SIP request or response
+type message struct {
+    method  string // Empty for a response
+    uri     string
+    status  int
+    reason  string
+    headers []header // In order, since Via and Record-Route order matters
+    body    []byte
+}
+
+type header struct {
+    name  string
+    value string
+}
+
+// compactNames expands the single-letter header forms of RFC 3261 7.3.3
+var compactNames = map[string]string{
+    "v": "Via",
+    "f": "From",
+    "t": "To",
+    "i": "Call-ID",
+    "m": "Contact",
+    "l": "Content-Length",
+    "c": "Content-Type",
+}
+
+// parseMessage parses one SIP message from a UDP datagram
+func parseMessage(data []byte) (*message, error) {
+    head, body, found := bytes.Cut(data, []byte("\r\n\r\n"))
+    if !found {
+        return nil, fmt.Errorf("no end of headers")
+    }
+    lines := strings.Split(string(head), "\r\n")
+
+    m := &message{}
+    parts := strings.SplitN(lines[0], " ", 3)
+    if len(parts) != 3 {
+        return nil, fmt.Errorf("bad start line %q", lines[0])
+    }
+    if strings.HasPrefix(parts[0], "SIP/") {
+        status, err := strconv.Atoi(parts[1])
+        if err != nil {
+            return nil, fmt.Errorf("bad status line %q", lines[0])
+        }
+        m.status, m.reason = status, parts[2]
+    } else {
+        m.method, m.uri = parts[0], parts[1]
+    }
+
+    for _, line := range lines[1:] {
+        if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(m.headers) > 0 {
+            // A folded continuation of the previous header
+            m.headers[len(m.headers)-1].value += " " + strings.TrimSpace(line)
+            continue
+        }
+        name, value, found := strings.Cut(line, ":")
+        if !found {
+            return nil, fmt.Errorf("bad header %q", line)
+        }
+        name = strings.TrimSpace(name)
+        if long, ok := compactNames[strings.ToLower(name)]; ok {
+            name = long
+        }
+        m.headers = append(m.headers, header{name: name, value: strings.TrimSpace(value)})
+    }
+
+    if length := m.get("Content-Length"); length != "" {
+        n, err := strconv.Atoi(length)
+        if err != nil || n < 0 || n > len(body) {
+            return nil, fmt.Errorf("bad Content-Length %q", length)
+        }
+        body = body[:n]
+    }
+    m.body = body
+    return m, nil
+}
+
+// get returns the first value of the named header
+func (m *message) get(name string) string {
+    for _, h := range m.headers {
+        if strings.EqualFold(h.name, name) {
+            return h.value
+        }
+    }
+    return ""
+}
+
+// all returns every value of the named header in order
+func (m *message) all(name string) []string {
+    var values []string
+    for _, h := range m.headers {
+        if strings.EqualFold(h.name, name) {
+            values = append(values, h.value)
+        }
+    }
+    return values
+}
+
+func (m *message) add(name, value string) {
+    m.headers = append(m.headers, header{name: name, value: value})
+}
+
+// reply builds a response to the request m, tagging the To header with
+// toTag unless the request already carries one
+func (m *message) reply(status int, reason, toTag string) *message {
+    r := &message{status: status, reason: reason}
+    for _, h := range m.headers {
+        switch {
+        case strings.EqualFold(h.name, "To"):
+            if toTag != "" && tag(h.value) == "" {
+                h.value += ";tag=" + toTag
+            }
+        case strings.EqualFold(h.name, "Via"), strings.EqualFold(h.name, "From"),
+            strings.EqualFold(h.name, "Call-ID"), strings.EqualFold(h.name, "CSeq"),
+            strings.EqualFold(h.name, "Record-Route"):
+        default:
+            continue
+        }
+        r.headers = append(r.headers, h)
+    }
+    return r
+}
+
+// bytes formats m for the wire, adding Content-Length
+func (m *message) bytes() []byte {
+    var b bytes.Buffer
+    if m.method != "" {
+        fmt.Fprintf(&b, "%s %s SIP/2.0\r\n", m.method, m.uri)
+    } else {
+        fmt.Fprintf(&b, "SIP/2.0 %d %s\r\n", m.status, m.reason)
+    }
+    for _, h := range m.headers {
+        if !strings.EqualFold(h.name, "Content-Length") {
+            fmt.Fprintf(&b, "%s: %s\r\n", h.name, h.value)
+        }
+    }
+    fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n", len(m.body))
+    b.Write(m.body)
+    return b.Bytes()
+}
+
+// describe names m for diagnostics
+func (m *message) describe() string {
+    if m.method != "" {
+        return m.method
+    }
+    return fmt.Sprintf("%d %s", m.status, m.reason)
+}
+
+// tag returns the tag parameter of a From or To header value
+func tag(value string) string {
+    // Parameters follow the URI, which may hold parameters of its own
+    // inside angle brackets
+    if i := strings.LastIndex(value, ">"); i >= 0 {
+        value = value[i+1:]
+    }
+    for _, param := range strings.Split(value, ";")[1:] {
+        name, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+        if strings.EqualFold(name, "tag") {
+            return v
+        }
+    }
+    return ""
+}
+
+// headerURI returns the URI of a From, To or Contact header value
+func headerURI(value string) string {
+    if start := strings.Index(value, "<"); start >= 0 {
+        if end := strings.Index(value[start:], ">"); end >= 0 {
+            return value[start+1 : start+end]
+        }
+    }
+    uri, _, _ := strings.Cut(value, ";")
+    return strings.TrimSpace(uri)
+}
@@ -0,0 +1,89 @@
+package sip
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestParseMessage(t *testing.T) {
+    raw := "INVITE sip:bot@192.0.2.1 SIP/2.0\r\n" +
+        "v: SIP/2.0/UDP 192.0.2.9:5060;branch=z9hG4bK1\r\n" +
+        "Via: SIP/2.0/UDP 192.0.2.8:5060;branch=z9hG4bK2\r\n" +
+        "f: \"Alice\" <sip:alice@example.com;transport=udp>;tag=abc\r\n" +
+        "To: <sip:bot@example.com>\r\n" +
+        "Subject: a long\r\n" +
+        " folded line\r\n" +
+        "l: 4\r\n" +
+        "\r\n" +
+        "bodyEXTRA"
+    m, err := parseMessage([]byte(raw))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if m.method != "INVITE" || m.uri != "sip:bot@192.0.2.1" {
+        t.Errorf("request line: %q %q", m.method, m.uri)
+    }
+    if vias := m.all("via"); len(vias) != 2 || !strings.Contains(vias[1], "192.0.2.8") {
+        t.Errorf("Via headers %q", vias)
+    }
+    if got := m.get("Subject"); got != "a long folded line" {
+        t.Errorf("folded header %q", got)
+    }
+    if got := tag(m.get("From")); got != "abc" {
+        t.Errorf("From tag %q", got)
+    }
+    if got := headerURI(m.get("From")); got != "sip:alice@example.com;transport=udp" {
+        t.Errorf("From URI %q", got)
+    }
+    if string(m.body) != "body" {
+        t.Errorf("body %q, want it cut at Content-Length", m.body)
+    }
+}
+
+func TestParseMessageResponse(t *testing.T) {
+    m, err := parseMessage([]byte("SIP/2.0 486 Busy Here\r\nCall-ID: x\r\n\r\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if m.method != "" || m.status != 486 || m.reason != "Busy Here" || m.describe() != "486 Busy Here" {
+        t.Errorf("got %+v", m)
+    }
+}
+
+func TestParseMessageErrors(t *testing.T) {
+    for name, raw := range map[string]string{
+        "no blank line":        "OPTIONS sip:x SIP/2.0\r\nCall-ID: x\r\n",
+        "short start line":     "OPTIONS sip:x\r\n\r\n",
+        "bad status":           "SIP/2.0 OK fine\r\n\r\n",
+        "header without colon": "OPTIONS sip:x SIP/2.0\r\nCall-ID\r\n\r\n",
+        "length past the body": "OPTIONS sip:x SIP/2.0\r\nContent-Length: 10\r\n\r\nshort",
+    } {
+        if _, err := parseMessage([]byte(raw)); err == nil {
+            t.Errorf("%s: parsed, want an error", name)
+        }
+    }
+}
+
+func TestReply(t *testing.T) {
+    m, err := parseMessage([]byte("BYE sip:bot SIP/2.0\r\n" +
+        "Via: SIP/2.0/UDP a;branch=z9hG4bK1\r\n" +
+        "From: <sip:alice@x>;tag=1\r\n" +
+        "To: <sip:bot@x>\r\n" +
+        "Call-ID: c\r\n" +
+        "CSeq: 2 BYE\r\n" +
+        "Max-Forwards: 70\r\n\r\n"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    wire := string(m.reply(200, "OK", "xyz").bytes())
+    want := "SIP/2.0 200 OK\r\n" +
+        "Via: SIP/2.0/UDP a;branch=z9hG4bK1\r\n" +
+        "From: <sip:alice@x>;tag=1\r\n" +
+        "To: <sip:bot@x>;tag=xyz\r\n" +
+        "Call-ID: c\r\n" +
+        "CSeq: 2 BYE\r\n" +
+        "Content-Length: 0\r\n\r\n"
+    if wire != want {
+        t.Errorf("got\n%q\nwant\n%q", wire, want)
+    }
+}
@@ -0,0 +1,145 @@
+package sip
+
+import (
+    "encoding/binary"
+    "fmt"
+    "net"
+    "strconv"
+    "strings"
+)
+
+// Codec is a G.711 RTP payload format a call can use
+type Codec struct {
+    Name        string // PCMU (u-law) or PCMA (A-law)
+    PayloadType uint8
+}
+
+// The static payload types of RFC 3551
+var (
+    PCMU = Codec{Name: "PCMU", PayloadType: 0}
+    PCMA = Codec{Name: "PCMA", PayloadType: 8}
+)
+
+const (
+    // frameBytes is 20ms of 8kHz G.711, the usual packet size
+    frameBytes    = 160
+    rtpHeaderSize = 12
+)
+
+// parseRTP returns the payload type and payload of an RTP packet
+func parseRTP(packet []byte) (uint8, []byte, error) {
+    if len(packet) < rtpHeaderSize || packet[0]>>6 != 2 {
+        return 0, nil, fmt.Errorf("not an RTP packet")
+    }
+    payloadType := packet[1] & 0x7f
+    offset := rtpHeaderSize + 4*int(packet[0]&0x0f) // Skip the CSRC list
+    if packet[0]&0x10 != 0 {
+        // A header extension: 4 bytes, then its length in words
+        if len(packet) < offset+4 {
+            return 0, nil, fmt.Errorf("short RTP extension")
+        }
+        offset += 4 + 4*int(binary.BigEndian.Uint16(packet[offset+2:]))
+    }
+    end := len(packet)
+    if packet[0]&0x20 != 0 && end > 0 {
+        // The last byte counts the padding
+        end -= int(packet[end-1])
+    }
+    if offset > end {
+        return 0, nil, fmt.Errorf("short RTP packet")
+    }
+    return payloadType, packet[offset:end], nil
+}
+
+// rtpPacket builds an RTP packet around payload
+func rtpPacket(payloadType uint8, marker bool, seq uint16, timestamp, ssrc uint32, payload []byte) []byte {
+    packet := make([]byte, rtpHeaderSize+len(payload))
+    packet[0] = 2 << 6
+    packet[1] = payloadType
+    if marker {
+        packet[1] |= 0x80
+    }
+    binary.BigEndian.PutUint16(packet[2:], seq)
+    binary.BigEndian.PutUint32(packet[4:], timestamp)
+    binary.BigEndian.PutUint32(packet[8:], ssrc)
+    copy(packet[rtpHeaderSize:], payload)
+    return packet
+}
+
+// offer is what the caller's SDP asks for
+type offer struct {
+    addr  *net.UDPAddr // Where the caller receives RTP
+    codec Codec
+}
+
+// parseOffer picks the first G.711 codec in an SDP offer and the address
+// its audio stream goes to
+func parseOffer(sdp []byte) (*offer, error) {
+    var sessionHost, mediaHost string
+    port := 0
+    var payloadTypes []string
+    inMedia, inAudio := false, false
+    for _, line := range strings.Split(string(sdp), "\n") {
+        line = strings.TrimRight(line, "\r")
+        switch {
+        case strings.HasPrefix(line, "m="):
+            fields := strings.Fields(line[2:])
+            inMedia = true
+            inAudio = len(fields) >= 4 && fields[0] == "audio" && port == 0
+            if inAudio {
+                port, _ = strconv.Atoi(fields[1])
+                payloadTypes = fields[3:]
+            }
+        case strings.HasPrefix(line, "c="):
+            // c=IN IP4 192.0.2.1; a media-level line overrides the session's
+            fields := strings.Fields(line[2:])
+            if len(fields) < 3 {
+                continue
+            }
+            if inAudio {
+                mediaHost = fields[2]
+            } else if !inMedia {
+                sessionHost = fields[2]
+            }
+        }
+    }
+    if port == 0 {
+        return nil, fmt.Errorf("no audio stream offered")
+    }
+
+    host := mediaHost
+    if host == "" {
+        host = sessionHost
+    }
+    ip := net.ParseIP(host)
+    if ip == nil {
+        return nil, fmt.Errorf("bad connection address %q", host)
+    }
+    for _, pt := range payloadTypes {
+        for _, codec := range []Codec{PCMU, PCMA} {
+            if pt == strconv.Itoa(int(codec.PayloadType)) {
+                return &offer{addr: &net.UDPAddr{IP: ip, Port: port}, codec: codec}, nil
+            }
+        }
+    }
+    return nil, fmt.Errorf("no G.711 codec offered")
+}
+
+// answerSDP describes the audio stream accepted for a call
+func answerSDP(host net.IP, port int, codec Codec, sessionID int64) []byte {
+    family := "IP4"
+    if host.To4() == nil {
+        family = "IP6"
+    }
+    return []byte(fmt.Sprintf("v=0\r\n"+
+        "o=geppetoaudio %d %d IN %s %s\r\n"+
+        "s=geppetoaudio\r\n"+
+        "c=IN %s %s\r\n"+
+        "t=0 0\r\n"+
+        "m=audio %d RTP/AVP %d\r\n"+
+        "a=rtpmap:%d %s/8000\r\n"+
+        "a=ptime:20\r\n"+
+        "a=sendrecv\r\n",
+        sessionID, sessionID, family, host, family, host,
+        port, codec.PayloadType, codec.PayloadType, codec.Name))
+}
@@ -0,0 +1,135 @@
+package sip
+
+import (
+    "bytes"
+    "net"
+    "testing"
+)
+
+func TestParseRTP(t *testing.T) {
+    payload := []byte{1, 2, 3, 4}
+    plain := rtpPacket(8, false, 7, 160, 99, payload)
+
+    withCSRC := append([]byte{0x82, 0}, make([]byte, 10+8)...) // Two CSRCs
+    withCSRC = append(withCSRC, payload...)
+
+    withExtension := append([]byte{0x90, 0}, make([]byte, 10)...)
+    withExtension = append(withExtension, 0xbe, 0xde, 0, 1, 9, 9, 9, 9) // One word
+    withExtension = append(withExtension, payload...)
+
+    padded := append(rtpPacket(0, false, 1, 1, 1, payload), 0, 0, 3)
+    padded[0] |= 0x20
+
+    tests := []struct {
+        name        string
+        packet      []byte
+        payloadType uint8
+        payload     []byte
+        ok          bool
+    }{
+        {"plain", plain, 8, payload, true},
+        {"marker bit", rtpPacket(0, true, 1, 1, 1, payload), 0, payload, true},
+        {"CSRC list", withCSRC, 0, payload, true},
+        {"header extension", withExtension, 0, payload, true},
+        {"padding", padded, 0, payload, true},
+        {"empty payload", rtpPacket(0, false, 1, 1, 1, nil), 0, []byte{}, true},
+        {"too short", plain[:11], 0, nil, false},
+        {"wrong version", append([]byte{0x40}, plain[1:]...), 0, nil, false},
+        {"CSRC list cut off", withCSRC[:18], 0, nil, false},
+        {"extension cut off", withExtension[:14], 0, nil, false},
+        {"padding past the header", append(append([]byte{0xa0}, plain[1:12]...), 200), 0, nil, false},
+    }
+    for _, tt := range tests {
+        payloadType, got, err := parseRTP(tt.packet)
+        if !tt.ok {
+            if err == nil {
+                t.Errorf("%s: parsed, want an error", tt.name)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        if payloadType != tt.payloadType || !bytes.Equal(got, tt.payload) {
+            t.Errorf("%s: got type %d payload %v, want %d %v", tt.name, payloadType, got, tt.payloadType, tt.payload)
+        }
+    }
+}
+
+func TestParseOffer(t *testing.T) {
+    tests := []struct {
+        name  string
+        sdp   string
+        addr  string
+        codec Codec
+        ok    bool
+    }{
+        {
+            name:  "PCMU first",
+            sdp:   "v=0\r\nc=IN IP4 192.0.2.1\r\nm=audio 4000 RTP/AVP 0 8 101\r\n",
+            addr:  "192.0.2.1:4000",
+            codec: PCMU,
+            ok:    true,
+        },
+        {
+            name:  "PCMA preferred over PCMU",
+            sdp:   "v=0\r\nc=IN IP4 192.0.2.1\r\nm=audio 4000 RTP/AVP 9 8 0\r\n",
+            addr:  "192.0.2.1:4000",
+            codec: PCMA,
+            ok:    true,
+        },
+        {
+            name:  "media connection overrides the session's",
+            sdp:   "v=0\nc=IN IP4 192.0.2.1\nm=audio 5004 RTP/AVP 0\nc=IN IP4 198.51.100.7\n",
+            addr:  "198.51.100.7:5004",
+            codec: PCMU,
+            ok:    true,
+        },
+        {
+            name:  "video stream ignored",
+            sdp:   "v=0\r\nc=IN IP4 192.0.2.1\r\nm=video 6000 RTP/AVP 96\r\nc=IN IP4 203.0.113.9\r\nm=audio 4002 RTP/AVP 8\r\n",
+            addr:  "192.0.2.1:4002",
+            codec: PCMA,
+            ok:    true,
+        },
+        {
+            name:  "IPv6",
+            sdp:   "v=0\r\nc=IN IP6 2001:db8::1\r\nm=audio 4000 RTP/AVP 0\r\n",
+            addr:  "[2001:db8::1]:4000",
+            codec: PCMU,
+            ok:    true,
+        },
+        {name: "no audio", sdp: "v=0\r\nc=IN IP4 192.0.2.1\r\nm=video 6000 RTP/AVP 96\r\n"},
+        {name: "no G.711", sdp: "v=0\r\nc=IN IP4 192.0.2.1\r\nm=audio 4000 RTP/AVP 9 18\r\n"},
+        {name: "no address", sdp: "v=0\r\nm=audio 4000 RTP/AVP 0\r\n"},
+        {name: "bad address", sdp: "v=0\r\nc=IN IP4 pbx.example.com\r\nm=audio 4000 RTP/AVP 0\r\n"},
+    }
+    for _, tt := range tests {
+        offer, err := parseOffer([]byte(tt.sdp))
+        if !tt.ok {
+            if err == nil {
+                t.Errorf("%s: parsed, want an error", tt.name)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%s: %v", tt.name, err)
+            continue
+        }
+        if offer.addr.String() != tt.addr || offer.codec != tt.codec {
+            t.Errorf("%s: got %v %s, want %s %s", tt.name, offer.addr, offer.codec.Name, tt.addr, tt.codec.Name)
+        }
+    }
+}
+
+func TestAnswerSDPParses(t *testing.T) {
+    sdp := answerSDP(net.ParseIP("192.0.2.5"), 4010, PCMA, 1)
+    offer, err := parseOffer(sdp)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if offer.addr.String() != "192.0.2.5:4010" || offer.codec != PCMA {
+        t.Errorf("answer describes %v %s", offer.addr, offer.codec.Name)
+    }
+}
@@ -0,0 +1,467 @@
+// Package sip answers SIP calls over UDP and exchanges their audio as
+// G.711 over RTP. It implements the part of a user agent server that a
+// PBX or SIP trunk needs to hand over a call: INVITE, ACK, BYE, CANCEL
+// and OPTIONS, one audio stream per call, and no authentication beyond a
+// list of the networks requests may come from.
+package sip
+
+import (
+    "crypto/rand"
+    "encoding/binary"
+    "encoding/hex"
+    "fmt"
+    "log/slog"
+    "net"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// Transaction timers of RFC 3261 17.1.1.1, used to resend the answer
+// until the caller acknowledges it
+const (
+    t1 = 500 * time.Millisecond
+    t2 = 4 * time.Second
+)
+
+const allowed = "INVITE, ACK, BYE, CANCEL, OPTIONS"
+
+// Server answers the calls it is offered from the networks in Allow.
+type Server struct {
+    // Host is the IP address callers are told to send requests and audio
+    // to. Empty uses the local address that routes to each caller, which
+    // is wrong behind NAT.
+    Host string
+    // Handler runs on a goroutine of its own for each answered call. The
+    // call is hung up when it returns, unless the caller already has.
+    Handler func(*Call)
+    // Allow lists the networks requests are accepted from, such as the
+    // PBX's address; others get 403 Forbidden. Empty allows only loopback.
+    Allow []*net.IPNet
+    // MaxCalls caps the calls in progress at once; calls beyond it get
+    // 486 Busy Here. Zero leaves the number unlimited.
+    MaxCalls int
+    // Log, if set, receives diagnostics such as rejected calls.
+    Log *slog.Logger
+
+    conn   net.PacketConn
+    mutex  sync.Mutex
+    calls  map[string]*Call // By Call-ID
+    closed atomic.Bool
+}
+
+// Serve answers calls arriving on conn until Close.
+func (s *Server) Serve(conn net.PacketConn) error {
+    s.mutex.Lock()
+    s.conn = conn
+    s.calls = make(map[string]*Call)
+    s.mutex.Unlock()
+
+    buf := make([]byte, 65535)
+    for {
+        n, addr, err := conn.ReadFrom(buf)
+        if err != nil {
+            if s.closed.Load() {
+                return nil
+            }
+            return err
+        }
+        from, ok := addr.(*net.UDPAddr)
+        if !ok || n <= 4 {
+            // Keep-alives are a bare CRLF or two
+            continue
+        }
+        m, err := parseMessage(buf[:n])
+        if err != nil {
+            s.warn("bad SIP message", "from", from, "err", err)
+            continue
+        }
+        // Responses, such as to a BYE, need nothing further
+        if m.method != "" {
+            s.handle(m, from)
+        }
+    }
+}
+
+// Close hangs up every call and stops Serve.
+func (s *Server) Close() error {
+    s.closed.Store(true)
+    s.mutex.Lock()
+    conn := s.conn
+    var calls []*Call
+    for _, call := range s.calls {
+        calls = append(calls, call)
+    }
+    s.mutex.Unlock()
+
+    for _, call := range calls {
+        call.Hangup()
+    }
+    if conn == nil {
+        return nil
+    }
+    return conn.Close()
+}
+
+func (s *Server) handle(m *message, from *net.UDPAddr) {
+    if !s.allowed(from.IP) {
+        s.warn("forbidden SIP request", "message", m.describe(), "from", from)
+        // An ACK gets no response, not even a refusal
+        if m.method != "ACK" {
+            s.send(m.reply(403, "Forbidden", newTag()), from)
+        }
+        return
+    }
+
+    s.mutex.Lock()
+    call := s.calls[m.get("Call-ID")]
+    s.mutex.Unlock()
+
+    switch m.method {
+    case "INVITE":
+        if call == nil {
+            s.invite(m, from)
+            return
+        }
+        // A retransmission, or a re-INVITE such as for hold: the stream
+        // stays as answered, but follows the caller if it moved
+        if offer, err := parseOffer(m.body); err == nil && !offer.addr.IP.IsUnspecified() {
+            call.peer.Store(offer.addr)
+        }
+        s.send(call.answer(m), from)
+    case "ACK":
+        if call != nil {
+            call.ackOnce.Do(func() { close(call.ack) })
+        }
+    case "BYE":
+        if call == nil {
+            s.send(m.reply(481, "Call/Transaction Does Not Exist", ""), from)
+            return
+        }
+        s.send(m.reply(200, "OK", call.localTag), from)
+        call.endOnce.Do(call.close)
+    case "CANCEL":
+        // Calls are answered at once, so there is never an INVITE left to
+        // cancel; the caller follows with a BYE
+        if call == nil {
+            s.send(m.reply(481, "Call/Transaction Does Not Exist", ""), from)
+            return
+        }
+        s.send(m.reply(200, "OK", call.localTag), from)
+    case "OPTIONS":
+        r := m.reply(200, "OK", newTag())
+        r.add("Allow", allowed)
+        r.add("Accept", "application/sdp")
+        s.send(r, from)
+    default:
+        r := m.reply(405, "Method Not Allowed", newTag())
+        r.add("Allow", allowed)
+        s.send(r, from)
+    }
+}
+
+// allowed reports whether requests from ip are accepted
+func (s *Server) allowed(ip net.IP) bool {
+    if len(s.Allow) == 0 {
+        return ip.IsLoopback()
+    }
+    for _, network := range s.Allow {
+        if network.Contains(ip) {
+            return true
+        }
+    }
+    return false
+}
+
+// invite answers a new call
+func (s *Server) invite(m *message, from *net.UDPAddr) {
+    // Only Serve's goroutine adds calls, so the count can't grow between
+    // this check and the call being added
+    s.mutex.Lock()
+    busy := s.MaxCalls > 0 && len(s.calls) >= s.MaxCalls
+    s.mutex.Unlock()
+    if busy {
+        s.warn("rejecting call", "from", headerURI(m.get("From")), "err", "too many calls")
+        s.send(m.reply(486, "Busy Here", newTag()), from)
+        return
+    }
+
+    offer, err := parseOffer(m.body)
+    if err != nil {
+        s.warn("rejecting call", "from", headerURI(m.get("From")), "err", err)
+        s.send(m.reply(488, "Not Acceptable Here", newTag()), from)
+        return
+    }
+    s.send(m.reply(100, "Trying", ""), from)
+
+    rtp, err := listenRTP()
+    if err != nil {
+        s.warn("rejecting call", "from", headerURI(m.get("From")), "err", err)
+        s.send(m.reply(500, "Server Internal Error", newTag()), from)
+        return
+    }
+    host := s.localIP(from)
+    sipPort := s.conn.LocalAddr().(*net.UDPAddr).Port
+    call := &Call{
+        ID:       m.get("Call-ID"),
+        From:     headerURI(m.get("From")),
+        Codec:    offer.codec,
+        server:   s,
+        invite:   m,
+        remote:   from,
+        localTag: newTag(),
+        hostPort: net.JoinHostPort(host.String(), fmt.Sprint(sipPort)),
+        sdp:      answerSDP(host, rtp.LocalAddr().(*net.UDPAddr).Port, offer.codec, time.Now().Unix()),
+        rtp:      rtp,
+        audio:    make(chan []byte, 50),
+        done:     make(chan struct{}),
+        ack:      make(chan struct{}),
+    }
+    call.peer.Store(offer.addr)
+    s.mutex.Lock()
+    s.calls[call.ID] = call
+    s.mutex.Unlock()
+
+    ok := call.answer(m)
+    s.send(ok, from)
+    go call.resend(ok.bytes())
+    go call.receive()
+    go call.transmit()
+    go func() {
+        s.Handler(call)
+        call.Hangup()
+    }()
+}
+
+// localIP returns the address to give a caller at addr
+func (s *Server) localIP(addr *net.UDPAddr) net.IP {
+    if ip := net.ParseIP(s.Host); ip != nil {
+        return ip
+    }
+    // Connecting a UDP socket sends nothing but picks the route
+    conn, err := net.DialUDP("udp", nil, addr)
+    if err != nil {
+        return net.IPv4(127, 0, 0, 1)
+    }
+    defer conn.Close()
+    return conn.LocalAddr().(*net.UDPAddr).IP
+}
+
+func (s *Server) send(m *message, to *net.UDPAddr) {
+    if _, err := s.conn.WriteTo(m.bytes(), to); err != nil {
+        s.warn("sending SIP message", "message", m.describe(), "to", to, "err", err)
+    }
+}
+
+func (s *Server) warn(msg string, args ...interface{}) {
+    if s.Log != nil {
+        s.Log.Warn(msg, args...)
+    }
+}
+
+// listenRTP opens a socket for a call's audio on an even port, as RTP
+// ports are by convention (RFC 3550 11)
+func listenRTP() (*net.UDPConn, error) {
+    for i := 0; i < 10; i++ {
+        conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+        if err != nil {
+            return nil, err
+        }
+        if conn.LocalAddr().(*net.UDPAddr).Port%2 == 0 {
+            return conn, nil
+        }
+        conn.Close()
+    }
+    return nil, fmt.Errorf("no even RTP port free")
+}
+
+// newTag returns a random From/To tag or branch suffix
+func newTag() string {
+    b := make([]byte, 8)
+    rand.Read(b)
+    return hex.EncodeToString(b)
+}
+
+// Call is an answered call. Its audio is 8kHz G.711 in Codec both ways.
+type Call struct {
+    ID    string // The SIP Call-ID
+    From  string // The caller's URI
+    Codec Codec
+
+    server   *Server
+    invite   *message
+    remote   *net.UDPAddr // Where the caller's requests come from
+    localTag string
+    hostPort string // This side's SIP address, as the caller reaches it
+    sdp      []byte
+    rtp      *net.UDPConn
+    peer     atomic.Pointer[net.UDPAddr] // Where the caller receives audio
+    audio    chan []byte
+    done     chan struct{}
+    ack      chan struct{}
+    ackOnce  sync.Once
+    endOnce  sync.Once
+
+    sendMutex sync.Mutex
+    pending   []byte // Audio waiting to be paced out
+}
+
+// Audio returns the caller's audio, one RTP payload at a time. It is
+// closed when the call ends.
+func (c *Call) Audio() <-chan []byte {
+    return c.audio
+}
+
+// Done is closed when the call ends.
+func (c *Call) Done() <-chan struct{} {
+    return c.done
+}
+
+// Write queues audio for the caller, which is sent in real time.
+func (c *Call) Write(audio []byte) {
+    c.sendMutex.Lock()
+    c.pending = append(c.pending, audio...)
+    c.sendMutex.Unlock()
+}
+
+// Flush drops audio queued but not yet sent, such as the rest of an
+// interrupted response.
+func (c *Call) Flush() {
+    c.sendMutex.Lock()
+    c.pending = nil
+    c.sendMutex.Unlock()
+}
+
+// Hangup ends the call, sending BYE unless the caller hung up first.
+func (c *Call) Hangup() {
+    c.endOnce.Do(func() {
+        c.server.send(c.bye(), c.remote)
+        c.close()
+    })
+}
+
+func (c *Call) close() {
+    close(c.done)
+    c.rtp.Close()
+    c.server.mutex.Lock()
+    delete(c.server.calls, c.ID)
+    c.server.mutex.Unlock()
+}
+
+// answer builds the 200 OK for an INVITE of this call
+func (c *Call) answer(invite *message) *message {
+    r := invite.reply(200, "OK", c.localTag)
+    r.add("Contact", fmt.Sprintf("<sip:geppetoaudio@%s>", c.hostPort))
+    r.add("Allow", allowed)
+    r.add("Content-Type", "application/sdp")
+    r.body = c.sdp
+    return r
+}
+
+// bye builds the request that hangs up from this side
+func (c *Call) bye() *message {
+    uri := headerURI(c.invite.get("Contact"))
+    if uri == "" {
+        uri = headerURI(c.invite.get("From"))
+    }
+    m := &message{method: "BYE", uri: uri}
+    m.add("Via", fmt.Sprintf("SIP/2.0/UDP %s;branch=z9hG4bK%s;rport", c.hostPort, newTag()))
+    m.add("Max-Forwards", "70")
+    // This side sent the INVITE's To and receives at its From
+    m.add("From", c.invite.get("To")+";tag="+c.localTag)
+    m.add("To", c.invite.get("From"))
+    m.add("Call-ID", c.ID)
+    m.add("CSeq", "1 BYE")
+    for _, route := range c.invite.all("Record-Route") {
+        m.add("Route", route)
+    }
+    return m
+}
+
+// resend repeats the answer until the caller acknowledges it, giving up
+// on the call if it never does
+func (c *Call) resend(response []byte) {
+    interval := t1
+    giveUp := time.After(64 * t1)
+    for {
+        select {
+        case <-c.ack:
+            return
+        case <-c.done:
+            return
+        case <-giveUp:
+            c.server.warn("no ACK, hanging up", "from", c.From)
+            c.Hangup()
+            return
+        case <-time.After(interval):
+            c.server.conn.WriteTo(response, c.remote)
+            interval = min(2*interval, t2)
+        }
+    }
+}
+
+// receive hands the caller's audio to Audio until the call ends
+func (c *Call) receive() {
+    defer close(c.audio)
+    buf := make([]byte, 1500)
+    for {
+        n, from, err := c.rtp.ReadFromUDP(buf)
+        if err != nil {
+            return
+        }
+        payloadType, payload, err := parseRTP(buf[:n])
+        if err != nil || payloadType != c.Codec.PayloadType {
+            // Such as DTMF events or comfort noise
+            continue
+        }
+        // Send audio back the way it came, which gets through NAT where
+        // the address in the SDP may not (symmetric RTP, RFC 4961)
+        if peer := c.peer.Load(); !peer.IP.Equal(from.IP) || peer.Port != from.Port {
+            c.peer.Store(from)
+        }
+        select {
+        case c.audio <- append([]byte(nil), payload...):
+        default:
+            // The handler has fallen behind; losing audio beats stalling
+        }
+    }
+}
+
+// transmit paces queued audio out in 20ms packets until the call ends
+func (c *Call) transmit() {
+    var random [10]byte
+    rand.Read(random[:])
+    ssrc := binary.BigEndian.Uint32(random[0:])
+    timestamp := binary.BigEndian.Uint32(random[4:])
+    seq := binary.BigEndian.Uint16(random[8:])
+
+    ticker := time.NewTicker(20 * time.Millisecond)
+    defer ticker.Stop()
+    talking := false
+    for {
+        select {
+        case <-c.done:
+            return
+        case <-ticker.C:
+        }
+
+        c.sendMutex.Lock()
+        n := min(len(c.pending), frameBytes)
+        frame := c.pending[:n:n]
+        c.pending = c.pending[n:]
+        if len(c.pending) == 0 {
+            c.pending = nil
+        }
+        c.sendMutex.Unlock()
+
+        if n > 0 {
+            // The marker bit flags the first packet after silence
+            packet := rtpPacket(c.Codec.PayloadType, !talking, seq, timestamp, ssrc, frame)
+            c.rtp.WriteToUDP(packet, c.peer.Load())
+            seq++
+        }
+        talking = n > 0
+        // The timestamp follows the sampling clock through silence too
+        timestamp += frameBytes
+    }
+}
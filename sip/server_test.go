@@ -0,0 +1,122 @@
+package sip
+
+import (
+    "fmt"
+    "net"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestAllowed(t *testing.T) {
+    _, lan, _ := net.ParseCIDR("10.0.0.0/8")
+    _, pbx, _ := net.ParseCIDR("2001:db8::5/128")
+    tests := []struct {
+        name  string
+        allow []*net.IPNet
+        ip    string
+        want  bool
+    }{
+        {"default loopback", nil, "127.0.0.1", true},
+        {"default IPv6 loopback", nil, "::1", true},
+        {"default elsewhere", nil, "192.0.2.1", false},
+        {"listed network", []*net.IPNet{lan, pbx}, "10.1.2.3", true},
+        {"listed address", []*net.IPNet{lan, pbx}, "2001:db8::5", true},
+        {"unlisted", []*net.IPNet{lan, pbx}, "2001:db8::6", false},
+        {"loopback once a list is given", []*net.IPNet{lan}, "127.0.0.1", false},
+    }
+    for _, tt := range tests {
+        s := &Server{Allow: tt.allow}
+        if got := s.allowed(net.ParseIP(tt.ip)); got != tt.want {
+            t.Errorf("%s: %s allowed %v, want %v", tt.name, tt.ip, got, tt.want)
+        }
+    }
+}
+
+// serve starts s on a loopback port and returns a client socket and the
+// server's address
+func serve(t *testing.T, s *Server) (*net.UDPConn, *net.UDPAddr) {
+    t.Helper()
+    conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    go s.Serve(conn)
+    t.Cleanup(func() { s.Close() })
+
+    client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { client.Close() })
+    return client, conn.LocalAddr().(*net.UDPAddr)
+}
+
+// invite sends an INVITE for callID and returns the status of the first
+// final response
+func invite(t *testing.T, client *net.UDPConn, server *net.UDPAddr, callID string) int {
+    t.Helper()
+    sdp := "v=0\r\nc=IN IP4 127.0.0.1\r\nm=audio 4000 RTP/AVP 0\r\n"
+    m := fmt.Sprintf("INVITE sip:bot@127.0.0.1 SIP/2.0\r\n"+
+        "Via: SIP/2.0/UDP %s;branch=z9hG4bK%s\r\n"+
+        "From: <sip:alice@127.0.0.1>;tag=1\r\n"+
+        "To: <sip:bot@127.0.0.1>\r\n"+
+        "Call-ID: %s\r\n"+
+        "CSeq: 1 INVITE\r\n"+
+        "Content-Type: application/sdp\r\n"+
+        "Content-Length: %d\r\n\r\n%s", client.LocalAddr(), callID, callID, len(sdp), sdp)
+    if _, err := client.WriteToUDP([]byte(m), server); err != nil {
+        t.Fatal(err)
+    }
+
+    buf := make([]byte, 65535)
+    for {
+        client.SetReadDeadline(time.Now().Add(2 * time.Second))
+        n, _, err := client.ReadFromUDP(buf)
+        if err != nil {
+            t.Fatalf("%s: no final response: %v", callID, err)
+        }
+        r, err := parseMessage(buf[:n])
+        if err != nil {
+            t.Fatal(err)
+        }
+        if r.status >= 200 && strings.Contains(r.get("Call-ID"), callID) {
+            return r.status
+        }
+    }
+}
+
+func TestServerForbidsUnlistedSources(t *testing.T) {
+    _, lan, _ := net.ParseCIDR("10.0.0.0/8")
+    answered := make(chan bool, 1)
+    s := &Server{Allow: []*net.IPNet{lan}, Handler: func(*Call) { answered <- true }}
+    client, addr := serve(t, s)
+
+    if got := invite(t, client, addr, "forbidden"); got != 403 {
+        t.Errorf("got %d, want 403", got)
+    }
+    select {
+    case <-answered:
+        t.Error("the handler ran for a forbidden call")
+    default:
+    }
+}
+
+func TestServerCapsCalls(t *testing.T) {
+    release := make(chan struct{})
+    s := &Server{MaxCalls: 1, Handler: func(c *Call) {
+        select {
+        case <-release:
+        case <-c.Done():
+        }
+    }}
+    client, addr := serve(t, s)
+    defer close(release)
+
+    if got := invite(t, client, addr, "first"); got != 200 {
+        t.Fatalf("first call: got %d, want 200", got)
+    }
+    if got := invite(t, client, addr, "second"); got != 486 {
+        t.Errorf("second call: got %d, want 486", got)
+    }
+}
@@ -0,0 +1,98 @@
+package subtitles
+
+import (
+    "fmt"
+    "io"
+    "strings"
+    "time"
+)
+
+// Delta is a fragment of transcript text together with the audio offset at
+// which it arrived.
+type Delta struct {
+    Offset time.Duration
+    Text   string
+}
+
+// Cue is a single subtitle shown from Start to End.
+type Cue struct {
+    Start time.Duration
+    End   time.Duration
+    Text  string
+}
+
+// maxCueChars is the longest cue built before starting a new one
+const maxCueChars = 80
+
+// BuildCues groups transcript deltas into cues, breaking at sentence ends
+// or when a cue grows too long. Each cue ends where the next begins; the
+// last ends at total.
+func BuildCues(deltas []Delta, total time.Duration) []Cue {
+    var cues []Cue
+    var text strings.Builder
+    var start time.Duration
+
+    flush := func() {
+        if t := strings.TrimSpace(text.String()); t != "" {
+            cues = append(cues, Cue{Start: start, Text: t})
+        }
+        text.Reset()
+    }
+
+    for _, d := range deltas {
+        if text.Len() == 0 {
+            start = d.Offset
+        }
+        text.WriteString(d.Text)
+
+        t := strings.TrimSpace(text.String())
+        if strings.HasSuffix(t, ".") || strings.HasSuffix(t, "?") || strings.HasSuffix(t, "!") || len(t) >= maxCueChars {
+            flush()
+        }
+    }
+    flush()
+
+    for i := range cues {
+        if i+1 < len(cues) {
+            cues[i].End = cues[i+1].Start
+        } else {
+            cues[i].End = total
+        }
+        // Keep every cue on screen for at least a moment
+        if cues[i].End <= cues[i].Start {
+            cues[i].End = cues[i].Start + time.Second
+        }
+    }
+    return cues
+}
+
+// WriteSRT writes cues in SubRip format.
+func WriteSRT(w io.Writer, cues []Cue) error {
+    for i, cue := range cues {
+        if _, err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n",
+            i+1, timestamp(cue.Start, ","), timestamp(cue.End, ","), cue.Text); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// WriteVTT writes cues in WebVTT format.
+func WriteVTT(w io.Writer, cues []Cue) error {
+    if _, err := io.WriteString(w, "WEBVTT\n\n"); err != nil {
+        return err
+    }
+    for _, cue := range cues {
+        if _, err := fmt.Fprintf(w, "%s --> %s\n%s\n\n",
+            timestamp(cue.Start, "."), timestamp(cue.End, "."), cue.Text); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// timestamp formats d as HH:MM:SS followed by sep and milliseconds
+func timestamp(d time.Duration, sep string) string {
+    ms := d.Milliseconds()
+    return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
@@ -0,0 +1,102 @@
+package subtitles
+
+import (
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestBuildCues(t *testing.T) {
+    ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+    long := strings.Repeat("word ", 20)
+
+    tests := []struct {
+        name   string
+        deltas []Delta
+        total  time.Duration
+        want   []Cue
+    }{
+        {
+            name:  "nothing",
+            total: time.Second,
+        },
+        {
+            name:   "sentences",
+            deltas: []Delta{{0, "Hello"}, {ms(300), " there."}, {ms(900), " How are"}, {ms(1400), " you?"}},
+            total:  ms(2000),
+            want:   []Cue{{0, ms(900), "Hello there."}, {ms(900), ms(2000), "How are you?"}},
+        },
+        {
+            name:   "unfinished sentence",
+            deltas: []Delta{{ms(100), "And then"}},
+            total:  ms(1500),
+            want:   []Cue{{ms(100), ms(1500), "And then"}},
+        },
+        {
+            name:   "long text breaks",
+            deltas: []Delta{{0, long}, {ms(4000), "end."}},
+            total:  ms(5000),
+            want:   []Cue{{0, ms(4000), strings.TrimSpace(long)}, {ms(4000), ms(5000), "end."}},
+        },
+        {
+            name:   "blank deltas",
+            deltas: []Delta{{0, "  "}, {ms(200), "Hi!"}},
+            total:  ms(800),
+            want:   []Cue{{0, ms(800), "Hi!"}},
+        },
+        {
+            name:   "last cue past the total",
+            deltas: []Delta{{ms(3000), "Late."}},
+            total:  ms(2000),
+            want:   []Cue{{ms(3000), ms(4000), "Late."}},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := BuildCues(tt.deltas, tt.total)
+            if len(got) != len(tt.want) {
+                t.Fatalf("got %+v, want %+v", got, tt.want)
+            }
+            for i := range got {
+                if got[i] != tt.want[i] {
+                    t.Errorf("cue %d: got %+v, want %+v", i, got[i], tt.want[i])
+                }
+            }
+        })
+    }
+}
+
+func TestWrite(t *testing.T) {
+    cues := []Cue{
+        {Start: 0, End: 1500 * time.Millisecond, Text: "Hello there."},
+        {Start: 61*time.Minute + 2*time.Second + 5*time.Millisecond, End: 62 * time.Minute, Text: "Bye."},
+    }
+    tests := []struct {
+        name  string
+        write func(*strings.Builder) error
+        want  string
+    }{
+        {
+            name:  "SRT",
+            write: func(b *strings.Builder) error { return WriteSRT(b, cues) },
+            want: "1\n00:00:00,000 --> 00:00:01,500\nHello there.\n\n" +
+                "2\n01:01:02,005 --> 01:02:00,000\nBye.\n\n",
+        },
+        {
+            name:  "VTT",
+            write: func(b *strings.Builder) error { return WriteVTT(b, cues) },
+            want: "WEBVTT\n\n" +
+                "00:00:00.000 --> 00:00:01.500\nHello there.\n\n" +
+                "01:01:02.005 --> 01:02:00.000\nBye.\n\n",
+        },
+    }
+    for _, tt := range tests {
+        var b strings.Builder
+        if err := tt.write(&b); err != nil {
+            t.Fatalf("%s: %v", tt.name, err)
+        }
+        if b.String() != tt.want {
+            t.Errorf("%s: got\n%q\nwant\n%q", tt.name, b.String(), tt.want)
+        }
+    }
+}
@@ -0,0 +1,195 @@
+package tracing
+
+import (
+    "context"
+    "os"
+    "sync"
+
+    "go.opentelemetry.io/otel"
+    "go.opentelemetry.io/otel/attribute"
+    "go.opentelemetry.io/otel/codes"
+    "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+    "go.opentelemetry.io/otel/sdk/resource"
+    sdktrace "go.opentelemetry.io/otel/sdk/trace"
+    semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+    "go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans this package creates
+const tracerName = "geppetoaudio"
+
+// Setup exports spans over OTLP/HTTP to endpoint, or to the endpoint named
+// by the standard OTEL_EXPORTER_OTLP_* variables when endpoint is empty.
+// Without either, tracing stays disabled and spans cost almost nothing.
+// The returned function flushes and stops the exporter.
+func Setup(ctx context.Context, service, endpoint string) (func(context.Context) error, error) {
+    if endpoint == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" &&
+        os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+        return func(context.Context) error { return nil }, nil
+    }
+
+    var opts []otlptracehttp.Option
+    if endpoint != "" {
+        opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+    }
+    exporter, err := otlptracehttp.New(ctx, opts...)
+    if err != nil {
+        return nil, err
+    }
+
+    res, err := resource.Merge(resource.Default(),
+        resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service)))
+    if err != nil {
+        return nil, err
+    }
+
+    provider := sdktrace.NewTracerProvider(
+        sdktrace.WithBatcher(exporter),
+        sdktrace.WithResource(res),
+    )
+    otel.SetTracerProvider(provider)
+    return provider.Shutdown, nil
+}
+
+// Turn traces one conversation turn. The turn span is the root; sending
+// the user item, waiting for the first delta, streaming the response and
+// handling it locally are its children, so a backend shows which of
+// network, model and client time dominates. All methods are safe on a nil
+// Turn and from any goroutine.
+type Turn struct {
+    mu       sync.Mutex
+    ctx      context.Context
+    span     trace.Span
+    response trace.Span
+    waiting  trace.Span
+    stream   trace.Span
+    ended    bool
+}
+
+// StartTurn begins the root span of a turn started by the user
+func StartTurn(kind string) *Turn {
+    ctx, span := otel.Tracer(tracerName).Start(context.Background(), "turn",
+        trace.WithAttributes(attribute.String("turn.kind", kind)))
+    return &Turn{ctx: ctx, span: span}
+}
+
+// Send wraps the write of a client event in a span: the returned function
+// ends it, recording err if the write failed
+func (t *Turn) Send(eventType, eventID string) func(error) {
+    if t == nil {
+        return func(error) {}
+    }
+    t.mu.Lock()
+    _, span := otel.Tracer(tracerName).Start(t.ctx, eventType,
+        trace.WithAttributes(attribute.String("event.id", eventID)))
+    t.mu.Unlock()
+    return func(err error) {
+        if err != nil {
+            span.RecordError(err)
+            span.SetStatus(codes.Error, err.Error())
+        }
+        span.End()
+    }
+}
+
+// ResponseRequested opens the response span once response.create has been
+// sent, closing any response still open from an earlier request
+func (t *Turn) ResponseRequested() {
+    if t == nil {
+        return
+    }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if t.ended {
+        return
+    }
+    t.endResponse("superseded")
+
+    tracer := otel.Tracer(tracerName)
+    ctx, response := tracer.Start(t.ctx, "response")
+    _, waiting := tracer.Start(ctx, "await first delta")
+    t.response, t.waiting = response, waiting
+    t.stream = nil
+}
+
+// Delta marks the arrival of a response delta. The first one ends the wait
+// and starts the stream span.
+func (t *Turn) Delta(responseID, kind string) {
+    if t == nil {
+        return
+    }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if t.waiting == nil {
+        return
+    }
+    t.waiting.SetAttributes(attribute.String("delta.kind", kind))
+    t.waiting.End()
+    t.waiting = nil
+
+    t.response.SetAttributes(attribute.String("response.id", responseID))
+    _, t.stream = otel.Tracer(tracerName).Start(trace.ContextWithSpan(t.ctx, t.response), "stream")
+}
+
+// ResponseDone closes the response span with the final status reported
+// by response.done
+func (t *Turn) ResponseDone(responseID, status string) {
+    if t == nil {
+        return
+    }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if t.response != nil {
+        t.response.SetAttributes(attribute.String("response.id", responseID))
+    }
+    t.endResponse(status)
+}
+
+// endResponse ends the open response spans. Callers hold t.mu.
+func (t *Turn) endResponse(status string) {
+    if t.response == nil {
+        return
+    }
+    if t.waiting != nil {
+        t.waiting.End()
+        t.waiting = nil
+    }
+    if t.stream != nil {
+        t.stream.End()
+        t.stream = nil
+    }
+    t.response.SetAttributes(attribute.String("response.status", status))
+    if status != "completed" {
+        t.response.SetStatus(codes.Error, status)
+    }
+    t.response.End()
+    t.response = nil
+}
+
+// Process starts a span for local handling of a finished response, such
+// as writing audio files; the returned function ends it
+func (t *Turn) Process(name string) func() {
+    if t == nil {
+        return func() {}
+    }
+    t.mu.Lock()
+    _, span := otel.Tracer(tracerName).Start(t.ctx, name)
+    t.mu.Unlock()
+    return func() { span.End() }
+}
+
+// End closes the turn and anything still open beneath it
+func (t *Turn) End(status string) {
+    if t == nil {
+        return
+    }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if t.ended {
+        return
+    }
+    t.ended = true
+    t.endResponse(status)
+    t.span.SetAttributes(attribute.String("turn.status", status))
+    t.span.End()
+}